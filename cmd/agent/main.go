@@ -4,11 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/siddontang/chaos/pkg/node"
+	"github.com/siddontang/chaos/pkg/util/net"
 
 	// register nemesis
 	_ "github.com/siddontang/chaos/pkg/nemesis"
@@ -20,6 +22,9 @@ import (
 var (
 	nodeAddr = flag.String("addr", ":8080", "node address")
 	logFile  = flag.String("log-file", "/root/node.log", "node log file")
+	iface    = flag.String("iface", "", "network interface for the network nemesis, empty means detecting automatically")
+	hosts    = flag.String("hosts", "", "hosts file in the /etc/hosts format to resolve the nodes, empty means using DNS")
+	journal  = flag.String("journal", node.DefaultJournal, "journal file of the active nemeses to heal after the agent crashes")
 )
 
 func main() {
@@ -34,13 +39,23 @@ func main() {
 
 	log.SetOutput(f)
 
+	net.SetDevice(*iface)
+	if len(*hosts) > 0 {
+		if err = net.LoadHosts(*hosts); err != nil {
+			log.Fatalf("load hosts file %s failed %v", *hosts, err)
+		}
+	}
+
 	log.Printf("begin to listen %s", *nodeAddr)
 	agent := node.NewAgent(*nodeAddr)
+	agent.SetJournal(*journal)
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		agent.Run()
+		if err := agent.Run(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("run agent failed %v", err)
+		}
 	}()
 
 	<-sigs
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -16,33 +17,75 @@ import (
 	"github.com/siddontang/chaos/pkg/core"
 	"github.com/siddontang/chaos/pkg/history"
 	"github.com/siddontang/chaos/pkg/nemesis"
+	"github.com/siddontang/chaos/pkg/store"
 	"github.com/siddontang/chaos/tidb"
 )
 
 var (
 	action = flag.String("action", "run", "action:run, setupdb, "+
-		"startpd, startkv, starttidb, startclient, shutdownclient, killkv")
+		"startpd, startkv, starttidb, startclient, shutdownclient, killkv, healall, run-all, list, show")
 	n            = flag.String("nodes", "", "nodes: 1,2,3,4, 5")
+	kvNodes      = flag.String("kv-nodes", "1,2,3,4,5", "tikv nodes for run-all: 1,2,3,4,5")
 	initData     = flag.Bool("initData", false, "if init data in database")
 	nodePort     = flag.Int("node-port", 8080, "node port")
 	requestCount = flag.Int("request-count", 500, "client test request count")
 	runTime      = flag.Duration("run-time", 10*time.Minute, "client test run time")
+	concurrency  = flag.Int("concurrency", 0, "how many clients run at the same time across the nodes, 0 means one client per node")
+	warmup       = flag.Duration("warmup", 5*time.Second, "how long the clients run before the nemeses, negative means no warmup")
+	chaosTime    = flag.Duration("chaos-time", 0, "how long the nemeses run, 0 means until the clients stop")
+	recoveryTime = flag.Duration("recovery-time", 0, "how long to wait after healing the nemeses before the final check")
+	rate         = flag.Float64("rate", 0, "target ops/sec of each client, 0 means no limit")
+	globalRate   = flag.Float64("global-rate", 0, "target ops/sec of all the clients, 0 means no limit")
+	openLoop     = flag.Bool("open-loop", false, "send the requests at the rate without waiting for the former requests")
 	clientCase   = flag.String("case", "bank", "client test case, like bank")
-	historyFile  = flag.String("history", "./history.log", "history file")
-	nemesises    = flag.String("nemesis", "", "nemesis, seperated by name, like random_kill,all_kill")
+	historyFile  = flag.String("history", "", "history file, empty means history.log in the run results directory")
+	storeDir     = flag.String("store", "store", "results store, each run keeps its results in <store>/<case>/<timestamp>")
+	runID        = flag.String("run-id", store.Latest, "run timestamp for show, latest means the latest run of the case")
+	seed         = flag.Int64("seed", 0, "random seed, 0 means a random seed")
+	collectData  = flag.Bool("collect-data", false, "collect the data directories with the logs after the run")
+	nemesises    = flag.String("nemesis", "", "nemesis, seperated by name, like random_kill,all_kill, "+
+		"or composed like partition_halves+random_kill*5, random_kill:3|random_pause, bump_clock/skew_clock")
 	nn           = flag.String("nemesis-nodes", "", "nemesis-nodes: 1,2,3,4,5")
+	killService  = flag.String("kill-service", tidb.SERVICE_TIKV, "service for random_kill, minor_kill, major_kill and all_kill: pd, tikv or tidb")
+	regionID     = flag.Uint64("region-id", 0, "region for kill_tikv_region_leader, 0 means a random region")
+	target       = flag.String("nemesis-target", "", "nemesis target resolved against the live cluster, "+
+		"like all, pd, pd_leader, tikv, tikv_region_leader, tidb, not_tidb or exactly n2,n4")
 )
 
 func main() {
 	flag.Parse()
 
+	switch *action {
+	case "list":
+		listRuns(store.New(*storeDir))
+		return
+	case "show":
+		showRun(store.New(*storeDir), *clientCase, *runID)
+		return
+	}
+
 	cfg := &control.Config{
 		DB:           "tidb",
 		NodePort:     *nodePort,
 		RequestCount: *requestCount,
 		RunTime:      *runTime,
+		Warmup:       *warmup,
+		ChaosTime:    *chaosTime,
+		RecoveryTime: *recoveryTime,
+		Rate:         *rate,
+		GlobalRate:   *globalRate,
+		OpenLoop:     *openLoop,
+		Concurrency:  *concurrency,
+		Name:         *clientCase,
+		StoreDir:     *storeDir,
+		Seed:         *seed,
+		CollectData:  *collectData,
 		History:      *historyFile,
+		Flags:        make(map[string]string),
 	}
+	flag.VisitAll(func(f *flag.Flag) {
+		cfg.Flags[f.Name] = f.Value.String()
+	})
 
 	var (
 		creator     core.ClientCreator
@@ -58,20 +101,26 @@ func main() {
 		log.Fatalf("invalid client test case %s", *clientCase)
 	}
 
-	for _, name := range strings.Split(*nemesises, ",") {
-		var g core.NemesisGenerator
-		name := strings.TrimSpace(name)
-		if len(name) == 0 {
+	var selector nemesis.Selector
+	if len(*target) > 0 {
+		var err error
+		if selector, err = nemesis.ParseSelector(*target); err != nil {
+			log.Fatalf("invalid nemesis target %s: %v", *target, err)
+		}
+	}
+
+	for _, expr := range strings.Split(*nemesises, ",") {
+		if len(strings.TrimSpace(expr)) == 0 {
 			continue
 		}
 
-		switch name {
-		case "random_kill", "all_kill", "minor_kill", "major_kill":
-			g = nemesis.NewKillGenerator("tidb", name)
-		case "random_drop", "all_drop", "minor_drop", "major_drop":
-			g = nemesis.NewDropGenerator(name)
-		default:
-			log.Fatalf("invalid nemesis generator")
+		g, err := nemesis.ParseGenerator(expr, newNemesisGenerator)
+		if err != nil {
+			log.Fatalf("invalid nemesis generator %s: %v", expr, err)
+		}
+
+		if selector != nil {
+			g = nemesis.NewTargetGenerator(selector, g)
 		}
 
 		nemesisGens = append(nemesisGens, g)
@@ -79,34 +128,15 @@ func main() {
 
 	c := control.NewController(cfg, creator, nemesisGens)
 
-	var ns []int
-	if *n == "" {
-		ns = []int{}
-	} else {
-		nss := strings.Split(*n, ",")
-		ns = make([]int, len(nss))
-		for i, v := range nss {
-			if x, err := strconv.Atoi(v); err == nil {
-				ns[i] = x
-			} else {
-				panic(fmt.Sprintf("node value error %s", v))
-			}
-		}
-	}
+	ns := parseNodes(*n)
 
 	var nemesisNodes []int
-	if *nn == "" {
-		nemesisNodes = []int{}
+	if *nn == "" && len(*target) > 0 {
+		// The target selects from n1 - n5, the pd node bootstraps the cluster
+		// and only runs pd, so we never disturb it.
+		nemesisNodes = []int{1, 2, 3, 4, 5}
 	} else {
-		ss := strings.Split(*nn, ",")
-		nemesisNodes = make([]int, len(ss))
-		for i, v := range ss {
-			if x, err := strconv.Atoi(v); err == nil {
-				nemesisNodes[i] = x
-			} else {
-				panic(fmt.Sprintf("nemesis-ndoes values error %s", ss))
-			}
-		}
+		nemesisNodes = parseNodes(*nn)
 	}
 
 	sigs := make(chan os.Signal, 1)
@@ -114,6 +144,10 @@ func main() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// done is closed when the action finishes its cleanup, like the teardown of run-all.
+	done := make(chan struct{})
+	close(done)
+
 	go func() {
 		<-sigs
 		c.Close()
@@ -137,25 +171,53 @@ func main() {
 	case "killkv":
 		c.KillServices(ns, tidb.SERVICE_TIKV)
 		cancel()
+	case "healall":
+		c.HealAll()
+		cancel()
 
 	case "run":
 		fmt.Printf("run client with initdata %v on %v and nemesis %v\n", *initData, ns, nemesisNodes)
 
-		c.Run(ns, *initData, nemesisNodes)
+		if err := c.Run(ns, *initData, nemesisNodes); err != nil {
+			log.Fatalf("%s run failed %v", *clientCase, err)
+		}
+
+		if stats, err := history.ReadStats(cfg.History, time.Second*10); err != nil {
+			log.Printf("read history %s stats failed %v", cfg.History, err)
+		} else {
+			log.Printf("%s history %s stats: %s", *clientCase, cfg.History, stats)
+		}
 
 		// Verify may take a long time, we should quit ASAP if receive signal.
 		go func() {
-			ok, err := verifier.Verify(*historyFile)
+			ok, err := verifier.Verify(cfg.History)
+			c.SaveResult(ok, err)
 			if err != nil {
 				log.Fatalf("verify history failed %v", err)
 			}
 
 			if !ok {
-				log.Fatalf("%s history %s is not linearizable", *clientCase, *historyFile)
+				c.Snapshot("failure")
+				log.Fatalf("%s history %s is not linearizable", *clientCase, cfg.History)
 			} else {
-				log.Printf("%s history %s is linearizable", *clientCase, *historyFile)
+				log.Printf("%s history %s is linearizable", *clientCase, cfg.History)
+			}
+
+			cancel()
+		}()
+
+	case "run-all":
+		fmt.Printf("run all with kv on %v, client on %v and nemesis %v\n", parseNodes(*kvNodes), ns, nemesisNodes)
+
+		// RunAll stops at once if receive signal, but we must wait for its teardown.
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := c.RunAll(parseNodes(*kvNodes), ns, *initData, nemesisNodes, verifier); err != nil {
+				log.Fatalf("%s run all failed %v", *clientCase, err)
 			}
 
+			log.Printf("%s history %s is linearizable", *clientCase, cfg.History)
 			cancel()
 		}()
 
@@ -165,4 +227,108 @@ func main() {
 
 	fmt.Println("Done")
 	<-ctx.Done()
+	<-done
+}
+
+// listRuns prints the runs of all the tests in the store.
+func listRuns(s *store.Store) {
+	tests, err := s.Tests()
+	if err != nil {
+		log.Fatalf("list tests failed %v", err)
+	}
+
+	for _, test := range tests {
+		runs, err := s.Runs(test)
+		if err != nil {
+			log.Fatalf("list runs of %s failed %v", test, err)
+		}
+		for _, r := range runs {
+			state := "unverified"
+			if result := r.Result(); result != nil && result.Valid {
+				state = "valid"
+			} else if result != nil {
+				state = "invalid " + result.Error
+			}
+			fmt.Printf("%s\t%s\t%s\n", test, r.Name, state)
+		}
+	}
+}
+
+// showRun prints the config, result, report and files of the run.
+func showRun(s *store.Store, test string, name string) {
+	r, err := s.Run(test, name)
+	if err != nil {
+		log.Fatalf("find run %s of %s failed %v", name, test, err)
+	}
+
+	fmt.Printf("run %s of %s in %s\n", r.Name, r.Test, r.Dir)
+	for _, file := range []string{store.ConfigFile, store.ResultFile, store.ReportFile} {
+		data, err := ioutil.ReadFile(r.Path(file))
+		if err != nil {
+			continue
+		}
+		fmt.Printf("\n%s:\n%s\n", file, strings.TrimSpace(string(data)))
+	}
+
+	infos, err := ioutil.ReadDir(r.Dir)
+	if err != nil {
+		log.Fatalf("list files of run %s failed %v", r.Dir, err)
+	}
+	fmt.Printf("\nfiles:\n")
+	for _, info := range infos {
+		fmt.Printf("%s\t%d\n", info.Name(), info.Size())
+	}
+}
+
+// parseNodes parses the node indices like 1,2,3.
+func parseNodes(s string) []int {
+	if s == "" {
+		return []int{}
+	}
+
+	ss := strings.Split(s, ",")
+	ns := make([]int, len(ss))
+	for i, v := range ss {
+		x, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			panic(fmt.Sprintf("node value error %s", v))
+		}
+		ns[i] = x
+	}
+	return ns
+}
+
+// newNemesisGenerator creates the nemesis generator by name, the generators
+// can be composed in the -nemesis flag, see nemesis.ParseGenerator.
+func newNemesisGenerator(name string) (core.NemesisGenerator, error) {
+	switch name {
+	case "random_kill", "all_kill", "minor_kill", "major_kill":
+		return nemesis.NewKillGenerator("tidb", *killService, name), nil
+	case "kill_pd_leader", "kill_random_pd", "kill_random_tikv", "kill_random_tidb":
+		return nemesis.NewKillGenerator("tidb", "", name), nil
+	case "kill_tikv_region_leader":
+		return nemesis.NewRegionLeaderKillGenerator("tidb", *regionID), nil
+	case "random_pause", "all_pause", "minor_pause", "major_pause":
+		return nemesis.NewPauseGenerator("tidb", tidb.SERVICE_TIKV, name), nil
+	case "random_drop", "all_drop", "minor_drop", "major_drop":
+		return nemesis.NewDropGenerator(name), nil
+	case "partition_halves", "partition_random_node", "partition_bridge", "partition_ring":
+		return nemesis.NewPartitionGenerator(name), nil
+	case "delay", "loss", "duplicate", "reorder", "corrupt":
+		return nemesis.NewNetemGenerator(name), nil
+	case "slow_link", "slow_pd", "slow_tikv":
+		return nemesis.NewShapeGenerator(name), nil
+	case "bump_clock", "strobe_clock", "skew_clock":
+		return nemesis.NewClockGenerator(name), nil
+	case "disk_fill", "disk_readonly", "disk_delay", "disk_error":
+		return nemesis.NewDiskGenerator(name), nil
+	case "truncate_tikv_wal", "corrupt_tikv_wal", "truncate_pd_wal", "corrupt_pd_wal":
+		return nemesis.NewTruncateGenerator("tidb", name), nil
+	case "tikv_membership", "pd_membership":
+		return nemesis.NewMemberGenerator("tidb", name), nil
+	case "pd_transfer_leader", "pd_split", "pd_merge", "pd_scatter", "pd_scheduler_storm":
+		return nemesis.NewSchedulerGenerator(name), nil
+	default:
+		return nil, fmt.Errorf("unknown nemesis generator %s", name)
+	}
 }
@@ -14,8 +14,43 @@ type Config struct {
 	RequestCount int
 	// RunTime controls how long the controller takes.
 	RunTime time.Duration
+	// Warmup is how long the clients run before the nemeses,
+	// zero means the default 5s and a negative value means no warmup.
+	Warmup time.Duration
+	// ChaosTime is how long the nemeses run, zero means until the clients stop.
+	// If set, the clients stop after the recovery.
+	ChaosTime time.Duration
+	// RecoveryTime is how long to wait after healing all the nemeses
+	// before the final check.
+	RecoveryTime time.Duration
+	// Concurrency is how many clients send requests at the same time,
+	// they are spread across the nodes. Zero means one client per node.
+	Concurrency int
+	// Rate is the target ops/sec of each client, zero means no limit.
+	Rate float64
+	// GlobalRate is the target ops/sec of all the clients, zero means no limit.
+	GlobalRate float64
+	// OpenLoop sends the requests on schedule at the rate without waiting
+	// for the former requests, so the load doesn't back off when the cluster
+	// stalls. The client must support concurrent Invoke in the open loop.
+	OpenLoop bool
 
-	// History file
+	// ReadyTimeout is how long to wait for a started service to be ready.
+	ReadyTimeout time.Duration
+
+	// Name is the test name, the default is the DB name.
+	Name string
+	// StoreDir keeps the results of every run in <StoreDir>/<Name>/<timestamp>.
+	StoreDir string
+	// Seed seeds the random requests and nemeses, zero means a random seed.
+	Seed int64
+	// CollectData collects the data directories with the logs after the run.
+	CollectData bool
+	// Flags are the command line flags of the run, like the nemesis spec
+	// and the test case, they are saved with the config to reproduce the run.
+	Flags map[string]string
+
+	// History file, the default is history.log in the run results directory.
 	History string 
 }
 
@@ -27,4 +62,26 @@ func (c *Config) adjust() {
 	if c.RunTime == 0 {
 		c.RunTime = 10 * time.Minute
 	}
+
+	if c.ReadyTimeout == 0 {
+		c.ReadyTimeout = time.Minute
+	}
+
+	if len(c.Name) == 0 {
+		c.Name = c.DB
+	}
+
+	if len(c.StoreDir) == 0 {
+		c.StoreDir = "store"
+	}
+
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+
+	if c.Warmup == 0 {
+		c.Warmup = 5 * time.Second
+	} else if c.Warmup < 0 {
+		c.Warmup = 0
+	}
 }
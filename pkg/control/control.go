@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/rand"
+	"path"
 	"sync"
 	"sync/atomic"
 
 	"github.com/siddontang/chaos/pkg/core"
 	"github.com/siddontang/chaos/pkg/history"
 	"github.com/siddontang/chaos/pkg/node"
+	"github.com/siddontang/chaos/pkg/store"
 	"github.com/siddontang/chaos/tidb"
 	"time"
 )
@@ -23,14 +26,21 @@ type Controller struct {
 	nodes       []string
 	nodeClients []*node.Client
 
-	clients []core.Client
+	clientCreator core.ClientCreator
+	workers       []*worker
 
 	nemesisGenerators []core.NemesisGenerator
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// run keeps the results of the run, it is created on demand.
+	run *store.Run
+
+	// proc is the last process ID assigned to the workers.
 	proc int64
+	// limiter limits the requests of all the workers.
+	limiter *limiter
 
 	recorder *history.Recorder
 }
@@ -43,29 +53,31 @@ func NewController(cfg *Config, clientCreator core.ClientCreator, nemesisGenerat
 		log.Fatalf("empty database")
 	}
 
-	r, err := history.NewRecorder(cfg.History)
-	if err != nil {
-		log.Fatalf("prepare history failed %v", err)
+	if cfg.OpenLoop && cfg.Rate <= 0 && cfg.GlobalRate <= 0 {
+		log.Fatalf("open loop needs a rate")
 	}
 
+	// The clients and nemeses derive their random sources from the seed.
+	rand.Seed(cfg.Seed)
+	log.Printf("random seed %d", cfg.Seed)
+
 	c := new(Controller)
 	c.cfg = cfg
 	c.ctx, c.cancel = context.WithCancel(context.Background())
-	c.recorder = r
+	c.clientCreator = clientCreator
+	c.limiter = newLimiter(cfg.GlobalRate, cfg.OpenLoop)
 	c.nemesisGenerators = nemesisGenerators
 
 	name := "pd"
 	c.nodes = append(c.nodes, name)
 	client := node.NewClient(name, fmt.Sprintf("%s:%d", name, cfg.NodePort))
 	c.nodeClients = append(c.nodeClients, client)
-	c.clients = append(c.clients, clientCreator.Create(name))
 	//db client
 	for i := 1; i <= 5; i++ {
 		name := fmt.Sprintf("n%d", i)
 		c.nodes = append(c.nodes, name)
 		client := node.NewClient(name, fmt.Sprintf("%s:%d", name, cfg.NodePort))
 		c.nodeClients = append(c.nodeClients, client)
-		c.clients = append(c.clients, clientCreator.Create(name))
 	}
 
 	return c
@@ -76,24 +88,66 @@ func (c *Controller) Close() {
 	c.cancel()
 }
 
-// Run runs the controller.
-func (c *Controller) Run(ns []int, initData bool, nemesisNodes []int) {
+// Run runs the controller through the test phases: setup, warmup without faults,
+// chaos with the nemeses, heal-all with a quiet recovery and the final check.
+// It returns the error if the run can't start, like failing to set up the clients.
+func (c *Controller) Run(ns []int, initData bool, nemesisNodes []int) error {
+	if len(ns) == 0 {
+		return fmt.Errorf("no node to run the clients")
+	}
+
 	//c.SetupDB()
-	c.SetupClients(ns, initData)
+	dir, err := c.RunDir()
+	if err != nil {
+		return err
+	}
+	if len(c.cfg.History) == 0 {
+		c.cfg.History = path.Join(dir, store.HistoryFile)
+	}
+	c.saveConfig(ns, initData, nemesisNodes)
+
+	r, err := history.NewRecorder(c.cfg.History)
+	if err != nil {
+		return fmt.Errorf("prepare history failed %v", err)
+	}
+	c.recorder = r
+
+	// Clean up the faults left by the last run.
+	c.HealAll()
+	c.Snapshot("start")
+
+	if err = c.SetupClients(ns, initData); err != nil {
+		c.recorder.Close()
+		return err
+	}
+	c.enterPhase(core.PhaseSetup)
+
+	clientCtx, cancelClients := context.WithTimeout(c.ctx, c.cfg.RunTime)
+	defer cancelClients()
 
-	n := len(ns)
 	var clientWg sync.WaitGroup
-	clientWg.Add(n)
-	for _, i := range ns {
-		go func(i int) {
+	clientWg.Add(len(c.workers))
+	for _, w := range c.workers {
+		go func(w *worker) {
 			defer clientWg.Done()
-			c.onClientLoop(i)
-		}(i)
+			c.onClientLoop(clientCtx, w)
+		}(w)
 	}
 
-	time.Sleep(5 * time.Second)
+	clientDone := make(chan struct{})
+	go func() {
+		clientWg.Wait()
+		close(clientDone)
+	}()
+
+	c.enterPhase(core.PhaseWarmup)
+	c.sleep(clientDone, c.cfg.Warmup)
 
+	c.enterPhase(core.PhaseChaos)
 	ctx, cancel := context.WithCancel(c.ctx)
+	if c.cfg.ChaosTime > 0 {
+		ctx, cancel = context.WithTimeout(c.ctx, c.cfg.ChaosTime)
+	}
 
 	var nemesisWg sync.WaitGroup
 	nemesisWg.Add(1)
@@ -102,17 +156,89 @@ func (c *Controller) Run(ns []int, initData bool, nemesisNodes []int) {
 		c.dispatchNemesis(ctx, nemesisNodes)
 	}()
 
-	clientWg.Wait()
+	select {
+	case <-clientDone:
+	case <-ctx.Done():
+	}
 
 	cancel()
 
 	nemesisWg.Wait()
 
+	c.HealAll()
+
+	c.enterPhase(core.PhaseRecovery)
+	c.sleep(nil, c.cfg.RecoveryTime)
+	if c.cfg.ChaosTime > 0 {
+		cancelClients()
+	}
+	<-clientDone
+
+	c.enterPhase(core.PhaseFinal)
+	c.finalCheck()
+
 	//c.ShutdownClient()
 	//c.TearDownDB()
-	c.CloseClients(ns)
+	c.CloseClients()
 
 	c.recorder.Close()
+
+	c.Snapshot("end")
+	c.Collect()
+	return nil
+}
+
+// sleep sleeps for the duration, it returns early if done is closed
+// or the controller is closed.
+func (c *Controller) sleep(done <-chan struct{}, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-done:
+	case <-c.ctx.Done():
+	}
+}
+
+// enterPhase tells the clients the test enters the phase.
+func (c *Controller) enterPhase(phase core.Phase) {
+	log.Printf("enter %s phase", phase)
+	for _, w := range c.workers {
+		client, ok := w.getClient().(core.PhaseClient)
+		if !ok {
+			continue
+		}
+		if err := client.OnPhase(c.ctx, w.node, phase); err != nil {
+			log.Printf("client %d on node %s enters %s phase failed %v", w.getProc(), w.node, phase, err)
+		}
+	}
+}
+
+// finalCheck invokes the final requests of the clients as new processes,
+// so the final state is checked with the history.
+func (c *Controller) finalCheck() {
+	ctx, cancel := context.WithTimeout(c.ctx, time.Minute)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, w := range c.workers {
+		client, ok := w.client.(core.FinalClient)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			proc := atomic.AddInt64(&c.proc, 1)
+			result := c.invoke(ctx, w, proc, client.FinalRequest())
+			if result.Type != core.OutcomeOK {
+				log.Printf("final check %d on node %s is %s %s", proc, w.node, result.Type, result.Error)
+			}
+		}(w)
+	}
+	wg.Wait()
 }
 
 /*func (c *Controller) syncExec(f func(i int)) {
@@ -158,10 +284,57 @@ func (c *Controller) SetupDBs() {
 }
 
 func (c *Controller) SetupDB(i int) {
+	if err := c.setupDB(i); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *Controller) setupDB(i int) error {
 	client := c.nodeClients[i]
 	log.Printf("set up database on %s", c.nodes[i])
 	if err := client.SetUpDB(c.cfg.DB, c.nodes); err != nil {
-		log.Fatalf("setup db %s at node %s failed %v", c.cfg.DB, c.nodes[i], err)
+		return fmt.Errorf("setup db %s at node %s failed %v", c.cfg.DB, c.nodes[i], err)
+	}
+	return nil
+}
+
+func (c *Controller) tearDownDB(i int) error {
+	client := c.nodeClients[i]
+	log.Printf("tear down database on %s", c.nodes[i])
+	if err := client.TearDownDB(c.cfg.DB, c.nodes); err != nil {
+		return fmt.Errorf("tear down db %s at node %s failed %v", c.cfg.DB, c.nodes[i], err)
+	}
+	return nil
+}
+
+// startService starts the service on the node.
+func (c *Controller) startService(i int, service string) error {
+	client := c.nodeClients[i]
+	log.Printf("start %s on node %s", service, c.nodes[i])
+	if err := client.Start(c.cfg.DB, service); err != nil {
+		return fmt.Errorf("start %s at node %s failed %v", service, c.nodes[i], err)
+	}
+	return c.waitReady(i, service)
+}
+
+// waitReady polls the service on the node until it is ready or timed out.
+func (c *Controller) waitReady(i int, service string) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.ReadyTimeout)
+	defer cancel()
+
+	client := c.nodeClients[i]
+	for {
+		err := client.Ready(c.cfg.DB, service)
+		if err == nil {
+			log.Printf("%s on node %s is ready", service, c.nodes[i])
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait %s on node %s ready failed %v", service, c.nodes[i], err)
+		case <-time.After(time.Second):
+		}
 	}
 }
 
@@ -175,10 +348,8 @@ func (c *Controller) SetupDB(i int) {
 
 func (c *Controller) StartPD() {
 	//pd
-	client := c.nodeClients[0]
-	log.Printf("start pd on node %s", c.nodes[0])
-	if err := client.Start(c.cfg.DB, tidb.SERVICE_PD); err != nil {
-		log.Fatalf("start pd at node %s failed %v", c.nodes[0], err)
+	if err := c.startService(0, tidb.SERVICE_PD); err != nil {
+		log.Fatal(err)
 	}
 }
 
@@ -187,10 +358,8 @@ func (c *Controller) StartKVs(ns []int) {
 }
 
 func (c *Controller) StartKV(i int) {
-	client := c.nodeClients[i]
-	log.Printf("start kv on node %s", c.nodes[i])
-	if err := client.Start(c.cfg.DB, tidb.SERVICE_TIKV); err != nil {
-		log.Fatalf("start kv at node %s failed %v", c.nodes[i], err)
+	if err := c.startService(i, tidb.SERVICE_TIKV); err != nil {
+		log.Fatal(err)
 	}
 }
 
@@ -198,49 +367,116 @@ func (c *Controller) StartTiDBs(ns []int) {
 	c.syncExec(ns, c.StartTiDB)
 }
 func (c *Controller) StartTiDB(i int) {
-	client := c.nodeClients[i]
-	log.Printf("start tidb on node %s", c.nodes[i])
-	if err := client.Start(c.cfg.DB, tidb.SERVICE_TIDB); err != nil {
-		log.Fatalf("start tidb at node %s failed %v", c.nodes[i], err)
+	if err := c.startService(i, tidb.SERVICE_TIDB); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func (c *Controller) SetupClients(ns []int, initData bool) {
-	var wg sync.WaitGroup
-	nl := len(ns)
-	wg.Add(nl)
-	for i, n := range ns {
-		go func(i int, n int) {
+// worker is a logical process which sends the requests to the node one by one
+// with its own client, the requests are recorded with its process ID.
+type worker struct {
+	node string
+	// mu protects the process ID and the client which are replaced when the process
+	// retires, the worker goroutine itself is the only writer and reads them freely.
+	mu     sync.Mutex
+	proc   int64
+	client core.Client
+}
+
+func (w *worker) getProc() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.proc
+}
+
+func (w *worker) getClient() core.Client {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.client
+}
+
+// SetupClients creates the workers spread across the nodes and sets up their clients.
+// The concurrency is one worker per node if not set.
+func (c *Controller) SetupClients(ns []int, initData bool) error {
+	if len(ns) == 0 {
+		return nil
+	}
+
+	n := c.cfg.Concurrency
+	if n <= 0 {
+		n = len(ns)
+	}
+
+	c.workers = make([]*worker, n)
+	for i := 0; i < n; i++ {
+		node := c.nodes[ns[i%len(ns)]]
+		c.workers[i] = &worker{
+			proc:   atomic.AddInt64(&c.proc, 1),
+			node:   node,
+			client: c.clientCreator.Create(node),
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	wg.Add(n)
+	for i, w := range c.workers {
+		go func(i int, w *worker) {
 			defer wg.Done()
-			if initData {
-				c.SetupClient(n, i == 0)
-			} else {
-				c.SetupClient(n, false)
+			// Only the first worker initializes the data.
+			if err := c.setupClient(w, initData && i == 0); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
 			}
-		}(i, n)
+		}(i, w)
 	}
 	wg.Wait()
+	return firstErr
 }
-func (c *Controller) SetupClient(i int, initData bool) {
-	node := c.nodes[i]
-	dbClient := c.clients[i]
-	log.Printf("setup db client for node %s", node)
-	if err := dbClient.Setup(c.ctx, node, initData); err != nil {
-		log.Fatalf("set up db client for node %s failed %v", node, err)
-	}
 
+func (c *Controller) setupClient(w *worker, initData bool) error {
+	log.Printf("setup db client %d for node %s", w.proc, w.node)
+	if err := w.client.Setup(c.ctx, w.node, initData); err != nil {
+		return fmt.Errorf("set up db client %d for node %s failed %v", w.proc, w.node, err)
+	}
+	return nil
 }
 
-func (c *Controller) CloseClients(ns []int) {
-	c.syncExec(ns, c.CloseClient)
+// CloseClients closes the clients of all the workers.
+func (c *Controller) CloseClients() {
+	var wg sync.WaitGroup
+	wg.Add(len(c.workers))
+	for _, w := range c.workers {
+		go func(w *worker) {
+			defer wg.Done()
+			log.Printf("shut down db client %d for node %s", w.proc, w.node)
+			if err := w.client.Close(c.ctx, c.nodes, w.node); err != nil {
+				log.Printf("shut down db client %d for node %s failed %v", w.proc, w.node, err)
+			}
+		}(w)
+	}
+	wg.Wait()
 }
-func (c *Controller) CloseClient(i int) {
-	client := c.clients[i]
-	node := c.nodes[i]
-	log.Printf("shut down db client for node %s", node)
-	if err := client.Close(c.ctx, c.nodes, node); err != nil {
-		log.Printf("shut down db client for node %s failed %v", node, err)
+
+// HealAll recovers all the active nemeses on all the nodes.
+func (c *Controller) HealAll() {
+	ns := make([]int, len(c.nodes))
+	for i := range c.nodes {
+		ns[i] = i
 	}
+	c.syncExec(ns, func(i int) {
+		if err := c.nodeClients[i].HealAll(); err != nil {
+			log.Printf("heal all the nemeses on node %s failed %v", c.nodes[i], err)
+		}
+	})
 }
 
 func (c *Controller) KillServices(ns []int, service string) {
@@ -275,33 +511,104 @@ func (c *Controller) Kill(i int, service string) {
 	})
 }*/
 
-func (c *Controller) onClientLoop(i int) {
-	client := c.clients[i]
-	node := c.nodes[i]
-	log.Printf("client %v running", i)
+func (c *Controller) onClientLoop(ctx context.Context, w *worker) {
+	log.Printf("client %d on node %s running", w.proc, w.node)
 
-	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.RunTime)
-	defer cancel()
+	l := newLimiter(c.cfg.Rate, c.cfg.OpenLoop)
+	if c.cfg.OpenLoop {
+		c.openLoop(ctx, w, l)
+		return
+	}
 
 	for i := 0; i < c.cfg.RequestCount; i++ {
-		procID := atomic.AddInt64(&c.proc, 1)
+		if c.wait(ctx, l) != nil {
+			return
+		}
 
-		request := client.NextRequest()
+		result := c.invoke(ctx, w, w.proc, w.client.NextRequest())
 
-		if err := c.recorder.RecordRequest(procID, request); err != nil {
-			log.Fatalf("record request %v failed %v", request, err)
+		select {
+		case <-ctx.Done():
+			return
+		default:
 		}
 
-		response := client.Invoke(ctx, node, request)
+		if result.Type == core.OutcomeInfo {
+			if !c.retire(ctx, w) {
+				return
+			}
+		}
+	}
+}
 
-		if err := c.recorder.RecordResponse(procID, response); err != nil {
-			log.Fatalf("record response %v failed %v", response, err)
+// openLoop sends the requests on schedule without waiting for the
+// former requests, each request runs as its own process.
+func (c *Controller) openLoop(ctx context.Context, w *worker, l *limiter) {
+	var wg sync.WaitGroup
+	for i := 0; i < c.cfg.RequestCount; i++ {
+		if c.wait(ctx, l) != nil {
+			break
 		}
 
+		proc := atomic.AddInt64(&c.proc, 1)
+		request := w.client.NextRequest()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.invoke(ctx, w, proc, request)
+		}()
+	}
+	wg.Wait()
+}
+
+// wait waits for both the client limiter and the global limiter.
+func (c *Controller) wait(ctx context.Context, l *limiter) error {
+	if err := l.Wait(ctx); err != nil {
+		return err
+	}
+	return c.limiter.Wait(ctx)
+}
+
+// invoke invokes the request as the process and records the history.
+func (c *Controller) invoke(ctx context.Context, w *worker, proc int64, request interface{}) core.Result {
+	if err := c.recorder.RecordRequest(proc, request); err != nil {
+		log.Fatalf("record request %v failed %v", request, err)
+	}
+
+	result := w.client.Invoke(ctx, w.node, request)
+
+	if err := c.recorder.RecordResponse(proc, result); err != nil {
+		log.Fatalf("record response %v failed %v", result, err)
+	}
+	return result
+}
+
+// retire retires the process of the worker after an indeterminate operation,
+// which may still take effect later, and reopens the client as a new process.
+// It returns false if the worker is stopped before reopening the client.
+func (c *Controller) retire(ctx context.Context, w *worker) bool {
+	if err := w.client.Close(ctx, c.nodes, w.node); err != nil {
+		log.Printf("close db client %d for node %s failed %v", w.proc, w.node, err)
+	}
+
+	old := w.proc
+	w.mu.Lock()
+	w.proc = atomic.AddInt64(&c.proc, 1)
+	w.client = c.clientCreator.Create(w.node)
+	w.mu.Unlock()
+	log.Printf("retire client %d for node %s, reopen as %d", old, w.node, w.proc)
+
+	for {
+		err := w.client.Setup(ctx, w.node, false)
+		if err == nil {
+			return true
+		}
+
+		log.Printf("reopen db client %d for node %s failed %v", w.proc, w.node, err)
 		select {
 		case <-ctx.Done():
-			return
-		default:
+			return false
+		case <-time.After(time.Second):
 		}
 	}
 }
@@ -318,26 +625,54 @@ func (c *Controller) dispatchNemesis(ctx context.Context, ns []int) {
 		nodes = append(nodes, c.nodes[v])
 	}
 
-LOOP:
-	//for {
+	for {
+		// Stop when all the generators are exhausted.
+		generated, dispatched := false, false
 		for _, g := range c.nemesisGenerators {
 			select {
 			case <-ctx.Done():
-				break LOOP
+				log.Printf("stop to run nemesis")
+				return
 			default:
 			}
 
 			log.Printf("begin to run %s nemesis generator on nodes %v", g.Name(), nodes)
 
-			ops := g.Generate(nodes)
+			var groups [][]*core.NemesisOperation
+			if cg, ok := g.(core.ConcurrentNemesisGenerator); ok {
+				groups = cg.GenerateGroups(nodes)
+			} else if ops := g.Generate(nodes); ops != nil {
+				groups = [][]*core.NemesisOperation{ops}
+			}
 
-			wg.Add(len(ops))
-			for i, op:= range ops {
-				go c.onNemesisLoop(ctx, ns[i], op, &wg)
+			if groups == nil {
+				continue
+			}
+			generated = true
+
+			// All the groups disturb the cluster at the same time.
+			for _, ops := range groups {
+				wg.Add(len(ops))
+				for i, op := range ops {
+					dispatched = dispatched || op != nil
+					go c.onNemesisLoop(ctx, ns[i], op, &wg)
+				}
 			}
 			wg.Wait()
 		}
-	//}
+
+		if !generated {
+			break
+		}
+
+		// Don't spin if the generators have nothing to disturb now.
+		if !dispatched {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
 	log.Printf("stop to run nemesis")
 }
 
@@ -1,6 +1,8 @@
 package control
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -10,16 +12,26 @@ import (
 func TestControl(t *testing.T) {
 	t.Log("test can only be run in the chaos docker")
 
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
 	cfg := &Config{
+		StoreDir:     dir,
 		NodePort:     8080,
 		RequestCount: 10,
 		RunTime:      10 * time.Second,
+		Concurrency:  4,
 		DB:           "noop",
 	}
 
 	c := NewController(cfg, core.NoopClientCreator{}, []core.NemesisGenerator{
 		core.NoopNemesisGenerator{},
 	})
-	c.Run()
+	if err = c.Run([]int{1, 2}, false, nil); err != nil {
+		t.Fatalf("run failed %v", err)
+	}
 	c.Close()
 }
@@ -0,0 +1,67 @@
+package control
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter paces the requests at a fixed rate.
+type limiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+	// openLoop keeps the schedule even if the requests fall behind,
+	// so the stalled cluster still sees a constant load later.
+	openLoop bool
+}
+
+// newLimiter creates a limiter with the rate in ops/sec, it returns
+// nil if the rate is not positive, which doesn't limit at all.
+func newLimiter(rate float64, openLoop bool) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &limiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		openLoop: openLoop,
+	}
+}
+
+// reserve reserves the time for the next request.
+func (l *limiter) reserve(now time.Time) time.Time {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.next.IsZero() || (!l.openLoop && l.next.Before(now)) {
+		// The closed loop doesn't send a burst to catch up.
+		l.next = now
+	}
+
+	at := l.next
+	l.next = at.Add(l.interval)
+	return at
+}
+
+// Wait waits until the next request can be sent.
+func (l *limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	d := time.Until(l.reserve(time.Now()))
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	if l := newLimiter(0, false); l != nil {
+		t.Fatal("zero rate must not limit")
+	}
+
+	now := time.Now()
+	l := newLimiter(10, false)
+	if at := l.reserve(now); !at.Equal(now) {
+		t.Fatalf("first request must be sent at once, got %s", at.Sub(now))
+	}
+	if at := l.reserve(now); at.Sub(now) != 100*time.Millisecond {
+		t.Fatalf("second request must wait 100ms, got %s", at.Sub(now))
+	}
+	// The closed loop falls behind and restarts from now.
+	later := now.Add(time.Second)
+	if at := l.reserve(later); !at.Equal(later) {
+		t.Fatalf("closed loop must not catch up, got %s", at.Sub(later))
+	}
+
+	l = newLimiter(10, true)
+	l.reserve(now)
+	// The open loop keeps the schedule.
+	if at := l.reserve(later); at.Sub(now) != 100*time.Millisecond {
+		t.Fatalf("open loop must keep the schedule, got %s", at.Sub(now))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newLimiter(0.001, false).Wait(ctx); err == nil {
+		t.Fatal("canceled wait must fail")
+	}
+}
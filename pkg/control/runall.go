@@ -0,0 +1,146 @@
+package control
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/siddontang/chaos/pkg/history"
+	"github.com/siddontang/chaos/tidb"
+)
+
+// Steps of RunAll.
+const (
+	StepSetup     = "setup"
+	StepStartPD   = "start pd"
+	StepStartKV   = "start kv"
+	StepStartTiDB = "start tidb"
+	StepRun       = "run"
+	StepVerify    = "verify"
+	StepTearDown  = "teardown"
+)
+
+// StepError is the error of a RunAll step.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Step, e.Err)
+}
+
+// execAll runs f on the nodes at the same time and returns the first error.
+func (c *Controller) execAll(ns []int, f func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	wg.Add(len(ns))
+	for _, i := range ns {
+		go func(i int) {
+			defer wg.Done()
+			if err := f(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// step runs the step, it fails fast if the controller is closed.
+func (c *Controller) step(name string, f func() error) error {
+	if err := c.ctx.Err(); err != nil {
+		return &StepError{Step: name, Err: err}
+	}
+
+	log.Printf("begin to %s", name)
+	if err := f(); err != nil {
+		c.Snapshot("failure")
+		c.Collect()
+		return &StepError{Step: name, Err: err}
+	}
+	return nil
+}
+
+// RunAll runs the whole test: sets up the database on all the nodes, starts
+// pd, tikv on kvNodes and tidb on dbNodes, runs the clients on dbNodes with
+// the nemeses, verifies the history and tears down the database at last.
+// It stops at the first failed step and returns a StepError.
+func (c *Controller) RunAll(kvNodes []int, dbNodes []int, initData bool, nemesisNodes []int, verifier history.Verifier) (err error) {
+	// No client means an empty history, which is always linearizable.
+	if len(dbNodes) == 0 {
+		return fmt.Errorf("no node to run the clients")
+	}
+
+	allNodes := make([]int, len(c.nodes))
+	for i := range c.nodes {
+		allNodes[i] = i
+	}
+
+	if err = c.step(StepSetup, func() error {
+		return c.execAll(allNodes, c.setupDB)
+	}); err != nil {
+		return err
+	}
+
+	// Tear down the database even if the test fails or is canceled,
+	// so the next run starts clean.
+	defer func() {
+		log.Printf("begin to %s", StepTearDown)
+		if tearDownErr := c.execAll(allNodes, c.tearDownDB); tearDownErr != nil {
+			tearDownErr = &StepError{Step: StepTearDown, Err: tearDownErr}
+			if err == nil {
+				err = tearDownErr
+			} else {
+				log.Print(tearDownErr)
+			}
+		}
+	}()
+
+	steps := []struct {
+		name    string
+		service string
+		ns      []int
+	}{
+		{StepStartPD, tidb.SERVICE_PD, []int{0}},
+		{StepStartKV, tidb.SERVICE_TIKV, kvNodes},
+		{StepStartTiDB, tidb.SERVICE_TIDB, dbNodes},
+	}
+	for _, s := range steps {
+		if err = c.step(s.name, func() error {
+			return c.execAll(s.ns, func(i int) error {
+				return c.startService(i, s.service)
+			})
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err = c.step(StepRun, func() error {
+		if err := c.Run(dbNodes, initData, nemesisNodes); err != nil {
+			return err
+		}
+		return c.ctx.Err()
+	}); err != nil {
+		return err
+	}
+
+	return c.step(StepVerify, func() error {
+		ok, err := verifier.Verify(c.cfg.History)
+		c.SaveResult(ok, err)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("history %s is not linearizable", c.cfg.History)
+		}
+		return nil
+	})
+}
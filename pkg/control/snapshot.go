@@ -0,0 +1,158 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/history"
+	"github.com/siddontang/chaos/pkg/node"
+	"github.com/siddontang/chaos/pkg/store"
+)
+
+// nodeSnapshot is the status of a node, or why we can't get it.
+type nodeSnapshot struct {
+	Node   string       `json:"node"`
+	Status *node.Status `json:"status,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// Snapshot gathers the status of all the nodes and saves them to
+// status-<tag>.json in the run results directory.
+func (c *Controller) Snapshot(tag string) {
+	snapshots := make([]nodeSnapshot, len(c.nodes))
+	ns := make([]int, len(c.nodes))
+	for i := range c.nodes {
+		ns[i] = i
+	}
+	c.syncExec(ns, func(i int) {
+		snapshots[i].Node = c.nodes[i]
+		status, err := c.nodeClients[i].Status()
+		if err != nil {
+			snapshots[i].Error = err.Error()
+			return
+		}
+		snapshots[i].Status = status
+	})
+
+	dir, err := c.RunDir()
+	if err != nil {
+		log.Printf("save %s snapshot failed %v", tag, err)
+		return
+	}
+
+	name := path.Join(dir, fmt.Sprintf("status-%s.json", tag))
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(name, data, 0644)
+	}
+	if err != nil {
+		log.Printf("save %s snapshot to %s failed %v", tag, name, err)
+		return
+	}
+	log.Printf("save %s snapshot to %s", tag, name)
+}
+
+// RunDir returns the results directory of the run in the store, the config,
+// history, snapshots, archives and results of the run are kept together in it.
+func (c *Controller) RunDir() (string, error) {
+	if c.run != nil {
+		return c.run.Dir, nil
+	}
+
+	run, err := store.New(c.cfg.StoreDir).NewRun(c.cfg.Name, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("create run in store %s failed %v", c.cfg.StoreDir, err)
+	}
+	c.run = run
+	return run.Dir, nil
+}
+
+// runConfig is the effective config of the run.
+type runConfig struct {
+	*Config
+	ClientNodes  []string `json:"client_nodes"`
+	NemesisNodes []string `json:"nemesis_nodes"`
+	Nemeses      []string `json:"nemeses"`
+	InitData     bool     `json:"init_data"`
+}
+
+// saveConfig saves the effective config of the run to the run results directory,
+// which must be created before.
+func (c *Controller) saveConfig(ns []int, initData bool, nemesisNodes []int) {
+	cfg := runConfig{Config: c.cfg, InitData: initData}
+	for _, i := range ns {
+		cfg.ClientNodes = append(cfg.ClientNodes, c.nodes[i])
+	}
+	for _, i := range nemesisNodes {
+		cfg.NemesisNodes = append(cfg.NemesisNodes, c.nodes[i])
+	}
+	for _, g := range c.nemesisGenerators {
+		cfg.Nemeses = append(cfg.Nemeses, g.Name())
+	}
+
+	if err := c.run.SaveJSON(store.ConfigFile, cfg); err != nil {
+		log.Printf("save config of run %s failed %v", c.run.Dir, err)
+	}
+}
+
+// SaveResult saves the verification result and the report of the history
+// stats to the run results directory.
+func (c *Controller) SaveResult(valid bool, verifyErr error) {
+	if _, err := c.RunDir(); err != nil {
+		log.Printf("save result failed %v", err)
+		return
+	}
+
+	result := store.Result{Valid: valid}
+	if verifyErr != nil {
+		result.Error = verifyErr.Error()
+	}
+	if err := c.run.SaveJSON(store.ResultFile, result); err != nil {
+		log.Printf("save result of run %s failed %v", c.run.Dir, err)
+	}
+
+	stats, err := history.ReadStats(c.cfg.History, 10*time.Second)
+	if err != nil {
+		log.Printf("read history %s stats failed %v", c.cfg.History, err)
+		return
+	}
+	if err = ioutil.WriteFile(c.run.Path(store.ReportFile), []byte(stats.String()+"\n"), 0644); err != nil {
+		log.Printf("save report of run %s failed %v", c.run.Dir, err)
+	}
+}
+
+// Collect pulls the archives of the db files on all the nodes
+// into <node>.tar.gz in the run results directory.
+func (c *Controller) Collect() {
+	ns := make([]int, len(c.nodes))
+	for i := range c.nodes {
+		ns[i] = i
+	}
+	dir, err := c.RunDir()
+	if err != nil {
+		log.Printf("collect files failed %v", err)
+		return
+	}
+	c.syncExec(ns, func(i int) {
+		name := path.Join(dir, fmt.Sprintf("%s.tar.gz", c.nodes[i]))
+		if err := c.collect(i, name); err != nil {
+			log.Printf("collect files on node %s failed %v", c.nodes[i], err)
+			os.Remove(name)
+		}
+	})
+}
+
+func (c *Controller) collect(i int, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.nodeClients[i].Archive(c.cfg.DB, c.cfg.CollectData, f)
+}
@@ -15,10 +15,81 @@ type RequestGenerator interface {
 type Client interface {
 	Setup(ctx context.Context, node string, initData bool) error
 	Close(ctx context.Context, nodes []string, node string) error
-	Invoke(ctx context.Context, node string, r interface{}) interface{}
+	Invoke(ctx context.Context, node string, r interface{}) Result
 	NextRequest() interface{}
 }
 
+// Phase is the phase of the test lifecycle.
+type Phase string
+
+// Test phases, in order.
+const (
+	// PhaseSetup sets up the clients and the data.
+	PhaseSetup Phase = "setup"
+	// PhaseWarmup runs the clients without faults.
+	PhaseWarmup Phase = "warmup"
+	// PhaseChaos runs the clients with the nemeses.
+	PhaseChaos Phase = "chaos"
+	// PhaseRecovery runs the clients after all the nemeses are healed.
+	PhaseRecovery Phase = "recovery"
+	// PhaseFinal reads the final state after the clients stop.
+	PhaseFinal Phase = "final"
+)
+
+// PhaseClient is the client which wants to know the test phases,
+// OnPhase may be called while the client is invoking a request.
+type PhaseClient interface {
+	Client
+	OnPhase(ctx context.Context, node string, phase Phase) error
+}
+
+// FinalClient is the client which checks the final state after recovery,
+// the final request is invoked and recorded like other requests.
+type FinalClient interface {
+	Client
+	FinalRequest() interface{}
+}
+
+// Outcome is the outcome of an operation.
+type Outcome string
+
+// Operation outcomes.
+const (
+	// OutcomeOK means the operation took effect.
+	OutcomeOK Outcome = "ok"
+	// OutcomeFail means the operation didn't take effect for sure.
+	OutcomeFail Outcome = "fail"
+	// OutcomeInfo means the operation is indeterminate, it may take effect
+	// even later, so the process which invoked it must be retired.
+	OutcomeInfo Outcome = "info"
+)
+
+// Result is the result of an operation, which the generic tooling
+// like the history stats understands for all the workloads.
+type Result struct {
+	// Type is the outcome of the operation.
+	Type Outcome
+	// Error is why the operation fails or is indeterminate.
+	Error string
+	// Value is the workload specific response.
+	Value interface{}
+}
+
+// OK returns an ok result with the value.
+func OK(value interface{}) Result {
+	return Result{Type: OutcomeOK, Value: value}
+}
+
+// Fail returns a fail result with the error.
+func Fail(err error) Result {
+	return Result{Type: OutcomeFail, Error: err.Error()}
+}
+
+// Info returns an indeterminate result with the error.
+func Info(err error) Result {
+	return Result{Type: OutcomeInfo, Error: err.Error()}
+}
+
 // ClientCreator creates a client.
 // The control will create one client for one node.
 type ClientCreator interface {
@@ -46,8 +117,8 @@ func (noopClient) Setup(ctx context.Context, node string, initData bool) error {
 func (noopClient) Close(ctx context.Context, nodes []string, node string) error { return nil }
 
 // Invoke invokes a request to the database.
-func (noopClient) Invoke(ctx context.Context, node string, r interface{}) interface{} {
-	return 0
+func (noopClient) Invoke(ctx context.Context, node string, r interface{}) Result {
+	return OK(0)
 }
 
 // NextRequest generates a request for latter Invoke.
@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 )
 
 // DB allows Chaos to set up and tear down database.
@@ -18,14 +20,59 @@ type DB interface {
 	Stop(ctx context.Context, service string) error
 	// Kill kills the database
 	Kill(ctx context.Context, service string) error
+	// Pause pauses the service process, the paused process still holds
+	// its resources but doesn't respond.
+	Pause(ctx context.Context, service string) error
+	// Resume resumes the paused service process.
+	Resume(ctx context.Context, service string) error
+	// Join adds the service on the node to the cluster membership.
+	Join(ctx context.Context, service string) error
+	// Leave removes the service on the node from the cluster membership.
+	Leave(ctx context.Context, service string) error
 	// IsRunning checks whether the database is running or not
 	IsRunning(ctx context.Context, service string) bool
+	// Ready checks whether the service is ready to serve, a started
+	// service may be running but not ready yet.
+	Ready(ctx context.Context, service string) error
 	// Name returns the unique name for the database
 	Name() string
 
 	Node() string
 }
 
+// ServiceStatus is the status of a database service on the node.
+type ServiceStatus struct {
+	Name    string        `json:"name"`
+	Running bool          `json:"running"`
+	PID     int           `json:"pid,omitempty"`
+	Uptime  time.Duration `json:"uptime,omitempty"`
+	// LogTail is the tail of the service log.
+	LogTail []string `json:"log_tail,omitempty"`
+}
+
+// DBStatus is the status of the database on the node.
+type DBStatus struct {
+	Name      string          `json:"name"`
+	Services  []ServiceStatus `json:"services,omitempty"`
+	DeployDir string          `json:"deploy_dir,omitempty"`
+	// DiskUsage is the size of the deploy directory in bytes.
+	DiskUsage int64 `json:"disk_usage,omitempty"`
+}
+
+// StatusDB is the DB which reports its status on the node.
+type StatusDB interface {
+	DB
+	Status(ctx context.Context) (DBStatus, error)
+}
+
+// ArchiveDB is the DB which can archive its files on the node for debugging.
+type ArchiveDB interface {
+	DB
+	// ArchiveFiles returns the files and directories to archive,
+	// like the logs and configs, withData adds the data directories.
+	ArchiveFiles(withData bool) []string
+}
+
 // NoopDB is a DB but does nothing
 type NoopDB struct {
 }
@@ -55,11 +102,36 @@ func (NoopDB) Kill(ctx context.Context, service string) error {
 	return nil
 }
 
+// Pause pauses the service process
+func (NoopDB) Pause(ctx context.Context, service string) error {
+	return nil
+}
+
+// Resume resumes the paused service process
+func (NoopDB) Resume(ctx context.Context, service string) error {
+	return nil
+}
+
+// Join adds the service to the cluster membership
+func (NoopDB) Join(ctx context.Context, service string) error {
+	return nil
+}
+
+// Leave removes the service from the cluster membership
+func (NoopDB) Leave(ctx context.Context, service string) error {
+	return nil
+}
+
 // IsRunning checks whether the database is running or not
 func (NoopDB) IsRunning(ctx context.Context, service string) bool {
 	return true
 }
 
+// Ready checks whether the service is ready to serve
+func (NoopDB) Ready(ctx context.Context, service string) error {
+	return nil
+}
+
 // Name returns the unique name for the database
 func (NoopDB) Name() string {
 	return "noop"
@@ -82,6 +154,16 @@ func RegisterDB(db DB) {
 	dbs[name] = db
 }
 
+// DBNames returns the names of the registered dbs in order.
+func DBNames() []string {
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetDB gets the registered db.
 func GetDB(name string) DB {
 	return dbs[name]
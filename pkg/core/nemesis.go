@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -69,16 +70,72 @@ func GetNemesis(name string) Nemesis {
 	return nemesises[name]
 }
 
+// TypedNemesis is the nemesis which declares a structured argument type,
+// the operation sends the arguments as JSON instead of the string list.
+type TypedNemesis interface {
+	Nemesis
+	// NewArgs returns a pointer to a new argument value to decode into.
+	NewArgs() interface{}
+	// InvokeWith executes the nemesis with the decoded arguments
+	InvokeWith(ctx context.Context, args interface{}) error
+	// RecoverWith recovers the nemesis with the decoded arguments
+	RecoverWith(ctx context.Context, args interface{}) error
+}
+
+func decodeArgs(n Nemesis, args json.RawMessage) (TypedNemesis, interface{}, error) {
+	tn, ok := n.(TypedNemesis)
+	if !ok {
+		return nil, nil, fmt.Errorf("nemesis %s doesn't support the structured arguments", n.Name())
+	}
+
+	v := tn.NewArgs()
+	if err := json.Unmarshal(args, v); err != nil {
+		return nil, nil, fmt.Errorf("invalid arguments for nemesis %s: %v", n.Name(), err)
+	}
+	return tn, v, nil
+}
+
+// InvokeNemesis invokes the nemesis with the structured arguments if
+// there are, or else with the string arguments.
+func InvokeNemesis(ctx context.Context, n Nemesis, args []string, typedArgs json.RawMessage) error {
+	if len(typedArgs) == 0 {
+		return n.Invoke(ctx, args...)
+	}
+
+	tn, v, err := decodeArgs(n, typedArgs)
+	if err != nil {
+		return err
+	}
+	return tn.InvokeWith(ctx, v)
+}
+
+// RecoverNemesis recovers the nemesis with the structured arguments if
+// there are, or else with the string arguments.
+func RecoverNemesis(ctx context.Context, n Nemesis, args []string, typedArgs json.RawMessage) error {
+	if len(typedArgs) == 0 {
+		return n.Recover(ctx, args...)
+	}
+
+	tn, v, err := decodeArgs(n, typedArgs)
+	if err != nil {
+		return err
+	}
+	return tn.RecoverWith(ctx, v)
+}
+
 // NemesisOperation is nemesis operation used in control.
 type NemesisOperation struct {
 	// Nemesis name
-	Name string
+	Name string `json:"name"`
 	// Nemesis invoke args
-	InvokeArgs []string
+	InvokeArgs []string `json:"invoke_args,omitempty"`
 	// Nemesis recover args
-	RecoverArgs []string
+	RecoverArgs []string `json:"recover_args,omitempty"`
+	// Args are the structured arguments for the TypedNemesis,
+	// used for both invoking and recovering instead of the string args.
+	Args json.RawMessage `json:"args,omitempty"`
 	// Nemesis execute time
-	RunTime time.Duration
+	RunTime time.Duration `json:"run_time"`
 }
 
 // NemesisGenerator is used in control, it will generate a nemesis operation
@@ -86,10 +143,20 @@ type NemesisOperation struct {
 type NemesisGenerator interface {
 	// Generate generates the nemesis operation for all nodes.
 	// Every node will be assigned a nemesis operation.
+	// Nil means the generator is exhausted and generates nothing anymore.
 	Generate(nodes []string) []*NemesisOperation
 	Name() string
 }
 
+// ConcurrentNemesisGenerator is the generator composed of the generators
+// which disturb the cluster at the same time. Every group has an operation
+// for each node like Generate, and the control runs all the groups concurrently,
+// so a node may run several nemeses at the same time.
+type ConcurrentNemesisGenerator interface {
+	NemesisGenerator
+	GenerateGroups(nodes []string) [][]*NemesisOperation
+}
+
 // NoopNemesisGenerator generates
 type NoopNemesisGenerator struct {
 }
@@ -6,8 +6,10 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/anishathalye/porcupine"
+	"github.com/siddontang/chaos/pkg/core"
 )
 
 // Operation action
@@ -20,6 +22,10 @@ type operation struct {
 	Action string          `json:"action"`
 	Proc   int64           `json:"proc"`
 	Data   json.RawMessage `json:"data"`
+	Time   time.Time       `json:"time"`
+	// Outcome and Error are only for the response.
+	Outcome core.Outcome `json:"outcome,omitempty"`
+	Error   string       `json:"error,omitempty"`
 }
 
 // Recorder records operation histogry.
@@ -45,25 +51,28 @@ func (r *Recorder) Close() {
 
 // RecordRequest records the request.
 func (r *Recorder) RecordRequest(proc int64, op interface{}) error {
-	return r.record(proc, InvokeOperation, op)
+	return r.record(operation{Action: InvokeOperation, Proc: proc}, op)
 }
 
-// RecordResponse records the response.
-func (r *Recorder) RecordResponse(proc int64, op interface{}) error {
-	return r.record(proc, ReturnOperation, op)
+// RecordResponse records the result, the value is recorded as the response data.
+func (r *Recorder) RecordResponse(proc int64, result core.Result) error {
+	v := operation{
+		Action:  ReturnOperation,
+		Proc:    proc,
+		Outcome: result.Type,
+		Error:   result.Error,
+	}
+	return r.record(v, result.Value)
 }
 
-func (r *Recorder) record(proc int64, action string, op interface{}) error {
+func (r *Recorder) record(v operation, op interface{}) error {
 	data, err := json.Marshal(op)
 	if err != nil {
 		return err
 	}
 
-	v := operation{
-		Action: action,
-		Proc:   proc,
-		Data:   json.RawMessage(data),
-	}
+	v.Data = json.RawMessage(data)
+	v.Time = time.Now()
 
 	data, err = json.Marshal(v)
 	if err != nil {
@@ -88,9 +97,10 @@ func (r *Recorder) record(proc int64, action string, op interface{}) error {
 type RecordParser interface {
 	// OnRequest parses the request record.
 	OnRequest(data json.RawMessage) (interface{}, error)
-	// OnResponse parses the response record. Return nil means
+	// OnResponse parses the value of the ok result. Return nil means
 	// the operation has an infinite end time.
-	// E.g, we meet timeout for a operation.
+	// The info results are infinite and the fail results are
+	// dropped without parsing.
 	OnResponse(data json.RawMessage) (interface{}, error)
 	// If we have some infinite operations, we should return a
 	// noop response to complete the operation.
@@ -121,6 +131,10 @@ func parseEvents(historyFile string, p RecordParser) ([]porcupine.Event, error)
 	defer f.Close()
 
 	procID := map[int64]uint{}
+	// pending are the operations with an infinite end time,
+	// their processes have moved on to the next operations.
+	var pending []uint
+	failed := map[uint]bool{}
 	id := uint(0)
 
 	events := make([]porcupine.Event, 0, 1024)
@@ -143,9 +157,23 @@ func parseEvents(historyFile string, p RecordParser) ([]porcupine.Event, error)
 				Value: value,
 			}
 			events = append(events, event)
+			if pendingID, ok := procID[op.Proc]; ok {
+				pending = append(pending, pendingID)
+			}
 			procID[op.Proc] = id
 			id++
 		} else {
+			switch op.Outcome {
+			case core.OutcomeInfo:
+				// The operation may take effect any time later.
+				continue
+			case core.OutcomeFail:
+				// The operation didn't take effect, so drop it.
+				failed[procID[op.Proc]] = true
+				delete(procID, op.Proc)
+				continue
+			}
+
 			if value, err = p.OnResponse(op.Data); err != nil {
 				return nil, err
 			}
@@ -170,6 +198,10 @@ func parseEvents(historyFile string, p RecordParser) ([]porcupine.Event, error)
 	}
 
 	for _, id := range procID {
+		pending = append(pending, id)
+	}
+
+	for _, id := range pending {
 		response := p.OnNoopResponse()
 		event := porcupine.Event{
 			Kind:  porcupine.ReturnEvent,
@@ -179,5 +211,15 @@ func parseEvents(historyFile string, p RecordParser) ([]porcupine.Event, error)
 		events = append(events, event)
 	}
 
-	return events, nil
+	if len(failed) == 0 {
+		return events, nil
+	}
+
+	completed := events[:0]
+	for _, event := range events {
+		if !failed[event.Id] {
+			completed = append(completed, event)
+		}
+	}
+	return completed, nil
 }
@@ -2,12 +2,15 @@ package history
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/anishathalye/porcupine"
+	"github.com/siddontang/chaos/pkg/core"
 )
 
 type noopRequest struct {
@@ -93,6 +96,9 @@ func TestHistory(t *testing.T) {
 		{2, noopResponse{Unknown: true}},
 		{3, noopRequest{Op: 0}},
 		{3, noopResponse{Value: 15}},
+		// The process goes on after the unknown operation.
+		{2, noopRequest{Op: 0}},
+		{2, noopResponse{Value: 15}},
 	}
 
 	for _, action := range actions {
@@ -102,7 +108,11 @@ func TestHistory(t *testing.T) {
 				t.Fatalf("record request failed %v", err)
 			}
 		case noopResponse:
-			if err = r.RecordResponse(action.proc, v); err != nil {
+			result := core.OK(v)
+			if v.Unknown {
+				result = core.Result{Type: core.OutcomeInfo}
+			}
+			if err = r.RecordResponse(action.proc, result); err != nil {
 				t.Fatalf("record response failed %v", err)
 			}
 		}
@@ -120,3 +130,73 @@ func TestHistory(t *testing.T) {
 		t.Fatal("must be linearizable")
 	}
 }
+
+func TestHistoryOutcome(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(".", "var")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	name := path.Join(tmpDir, "history.log")
+	r, err := NewRecorder(name)
+	if err != nil {
+		t.Fatalf("create recorder failed %v", err)
+	}
+
+	actions := []struct {
+		proc int64
+		op   interface{}
+	}{
+		// The failed write doesn't take effect.
+		{1, noopRequest{Op: 1, Value: 20}},
+		{1, core.Fail(errors.New("write conflict"))},
+		{1, noopRequest{Op: 0}},
+		{1, core.OK(noopResponse{Value: 10})},
+		// The indeterminate write takes effect later.
+		{2, noopRequest{Op: 1, Value: 30}},
+		{2, core.Info(errors.New("timeout"))},
+		{3, noopRequest{Op: 0}},
+		{3, core.OK(noopResponse{Value: 10})},
+		{4, noopRequest{Op: 0}},
+		{4, core.OK(noopResponse{Value: 30})},
+		// The process never returns.
+		{5, noopRequest{Op: 0}},
+	}
+
+	for _, action := range actions {
+		if result, ok := action.op.(core.Result); ok {
+			err = r.RecordResponse(action.proc, result)
+		} else {
+			err = r.RecordRequest(action.proc, action.op)
+		}
+		if err != nil {
+			t.Fatalf("record %v failed %v", action.op, err)
+		}
+	}
+
+	r.Close()
+
+	ok, err := VerifyHistory(name, getNoopModel(), noopParser{})
+	if err != nil {
+		t.Fatalf("verify history failed %v", err)
+	}
+
+	if !ok {
+		t.Fatal("must be linearizable")
+	}
+
+	stats, err := ReadStats(name, time.Minute)
+	if err != nil {
+		t.Fatalf("read stats failed %v", err)
+	}
+
+	if stats.OK != 3 || stats.Fail != 1 || stats.Info != 2 || stats.Errors["timeout"] != 1 {
+		t.Fatalf("invalid stats %+v", stats)
+	}
+
+	if n := len(stats.Timeline); n == 0 || n > 2 {
+		t.Fatalf("invalid timeline %+v", stats.Timeline)
+	}
+}
@@ -0,0 +1,161 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+)
+
+// Bucket counts the outcomes of the operations returned in a time window.
+type Bucket struct {
+	Start time.Time `json:"start"`
+	OK    int       `json:"ok"`
+	Fail  int       `json:"fail"`
+	Info  int       `json:"info"`
+}
+
+// Stats is the workload independent statistics of the history.
+type Stats struct {
+	OK   int `json:"ok"`
+	Fail int `json:"fail"`
+	// Info includes the operations which never return.
+	Info int `json:"info"`
+	// Errors counts the operations by the error.
+	Errors map[string]int `json:"errors"`
+	// MaxLatency is the max latency of the ok operations.
+	MaxLatency time.Duration `json:"max_latency"`
+	// Timeline buckets the outcomes by the return time.
+	Timeline []Bucket `json:"timeline"`
+}
+
+// Total returns the number of the operations.
+func (s *Stats) Total() int {
+	return s.OK + s.Fail + s.Info
+}
+
+// Availability returns the ratio of the ok operations.
+func (s *Stats) Availability() float64 {
+	if s.Total() == 0 {
+		return 0
+	}
+	return float64(s.OK) / float64(s.Total())
+}
+
+// statsReader accumulates the statistics while reading the history.
+type statsReader struct {
+	s        *Stats
+	interval time.Duration
+	buckets  map[time.Time]*Bucket
+}
+
+func (r *statsReader) add(outcome core.Outcome, errMsg string, t time.Time) error {
+	start := t.Truncate(r.interval)
+	b, ok := r.buckets[start]
+	if !ok {
+		b = &Bucket{Start: start}
+		r.buckets[start] = b
+	}
+
+	s := r.s
+	switch outcome {
+	case core.OutcomeOK:
+		s.OK++
+		b.OK++
+	case core.OutcomeFail:
+		s.Fail++
+		b.Fail++
+	case core.OutcomeInfo:
+		s.Info++
+		b.Info++
+	default:
+		return fmt.Errorf("unknown outcome %s", outcome)
+	}
+
+	if len(errMsg) > 0 {
+		s.Errors[errMsg]++
+	}
+	return nil
+}
+
+// String returns the summary of the statistics.
+func (s *Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "total %d, ok %d, fail %d, info %d, availability %.2f%%, max latency %s",
+		s.Total(), s.OK, s.Fail, s.Info, s.Availability()*100, s.MaxLatency)
+	for _, bucket := range s.Timeline {
+		fmt.Fprintf(&b, "\n%s ok %d, fail %d, info %d", bucket.Start.Format(time.RFC3339), bucket.OK, bucket.Fail, bucket.Info)
+	}
+	return b.String()
+}
+
+// ReadStats reads the statistics from the history file, the outcomes are
+// bucketed by the interval in the timeline. It works for all the workloads.
+func ReadStats(historyFile string, interval time.Duration) (*Stats, error) {
+	f, err := os.Open(historyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &Stats{Errors: map[string]int{}}
+	r := &statsReader{s: s, interval: interval, buckets: map[time.Time]*Bucket{}}
+	calls := map[int64]time.Time{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var op operation
+		if err = json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return nil, err
+		}
+
+		if op.Action == InvokeOperation {
+			if start, ok := calls[op.Proc]; ok {
+				// The process moved on without the return.
+				if err = r.add(core.OutcomeInfo, "", start); err != nil {
+					return nil, err
+				}
+			}
+			calls[op.Proc] = op.Time
+			continue
+		}
+
+		outcome := op.Outcome
+		if len(outcome) == 0 {
+			outcome = core.OutcomeOK
+		}
+		if err = r.add(outcome, op.Error, op.Time); err != nil {
+			return nil, err
+		}
+
+		if start, ok := calls[op.Proc]; ok && outcome == core.OutcomeOK {
+			if latency := op.Time.Sub(start); latency > s.MaxLatency {
+				s.MaxLatency = latency
+			}
+		}
+		delete(calls, op.Proc)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, start := range calls {
+		if err = r.add(core.OutcomeInfo, "", start); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range r.buckets {
+		s.Timeline = append(s.Timeline, *b)
+	}
+	sort.Slice(s.Timeline, func(i, j int) bool {
+		return s.Timeline[i].Start.Before(s.Timeline[j].Start)
+	})
+	return s, nil
+}
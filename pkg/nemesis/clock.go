@@ -0,0 +1,267 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util"
+)
+
+// Clock nemesis modes.
+const (
+	clockBump   = "bump"
+	clockStrobe = "strobe"
+	clockSkew   = "skew"
+)
+
+// clockTick is how often the skew mode adjusts the clock.
+const clockTick = 100 * time.Millisecond
+
+type clockOptions struct {
+	mode string
+	// delta is the offset for bump and strobe.
+	delta time.Duration
+	// period is how often strobe flips the clock.
+	period time.Duration
+	// rate is how fast the clock runs in skew, 2 means twice as fast.
+	rate float64
+}
+
+// parseClockArgs parses arguments like mode=bump, delta=-5s, period=10ms and rate=1.5.
+func parseClockArgs(args []string) (clockOptions, error) {
+	opts := clockOptions{
+		mode:   clockBump,
+		period: 10 * time.Millisecond,
+		rate:   1,
+	}
+
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return opts, err
+		}
+
+		switch key {
+		case "mode":
+			opts.mode = value
+		case "delta":
+			opts.delta, err = time.ParseDuration(value)
+		case "period":
+			opts.period, err = time.ParseDuration(value)
+		case "rate":
+			opts.rate, err = strconv.ParseFloat(value, 64)
+		default:
+			err = fmt.Errorf("unknown clock argument %s", key)
+		}
+
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	switch opts.mode {
+	case clockBump, clockSkew:
+	case clockStrobe:
+		if opts.period <= 0 {
+			return opts, fmt.Errorf("invalid strobe period %s", opts.period)
+		}
+	default:
+		return opts, fmt.Errorf("unknown clock mode %s", opts.mode)
+	}
+
+	return opts, nil
+}
+
+// clock bumps, strobes or skews the system clock of the node.
+// It remembers how far it has moved the clock, so it can move the clock
+// back precisely when recovering. The offset is lost if the agent restarts,
+// so the first recovery of a new agent, like the journal replay or heal-all,
+// resyncs the clock from the hardware clock instead.
+// Notice the nodes share the same clock if they run in containers
+// on the same host.
+type clock struct {
+	sync.Mutex
+	offset time.Duration
+	// tracked is true once the offset covers all the changes of the clock,
+	// either this agent has moved the clock or has resynced it.
+	tracked bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (n *clock) bump(delta time.Duration) error {
+	n.Lock()
+	defer n.Unlock()
+
+	if err := util.BumpClock(delta); err != nil {
+		return err
+	}
+	n.offset += delta
+	n.tracked = true
+	return nil
+}
+
+func (n *clock) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseClockArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if opts.mode == clockBump {
+		return n.bump(opts.delta)
+	}
+
+	// Strobe and skew keep changing the clock in the background until recovering.
+	n.stop()
+
+	var bgCtx context.Context
+	bgCtx, n.cancel = context.WithCancel(context.Background())
+
+	tick := clockTick
+	if opts.mode == clockStrobe {
+		tick = opts.period
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		flipped := false
+		for {
+			select {
+			case <-bgCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			delta := time.Duration(float64(tick) * (opts.rate - 1))
+			if opts.mode == clockStrobe {
+				delta = opts.delta
+				if flipped {
+					delta = -delta
+				}
+				flipped = !flipped
+			}
+
+			if err := n.bump(delta); err != nil {
+				log.Printf("bump clock %s for %s failed %v, stop changing the clock", delta, opts.mode, err)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (n *clock) stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.wg.Wait()
+		n.cancel = nil
+	}
+}
+
+func (n *clock) Recover(ctx context.Context, args ...string) error {
+	n.stop()
+
+	n.Lock()
+	offset, tracked := n.offset, n.tracked
+	n.Unlock()
+
+	if !tracked {
+		// The clock may be moved by the previous agent.
+		if err := util.ResyncClock(ctx); err != nil {
+			return err
+		}
+		n.Lock()
+		n.tracked = true
+		n.Unlock()
+		return nil
+	}
+
+	if offset == 0 {
+		return nil
+	}
+	return n.bump(-offset)
+}
+
+func (*clock) Name() string {
+	return "clock"
+}
+
+type clockGenerator struct {
+	name string
+}
+
+func (g clockGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	if len(nodes) == 0 {
+		return ops
+	}
+
+	// Randomly choose some nodes to disturb.
+	indices := shuffleIndices(len(nodes))
+	n := rand.Intn(len(nodes)) + 1
+	runTime := time.Second * time.Duration(rand.Intn(10)+1)
+
+	for i := 0; i < n; i++ {
+		ops[indices[i]] = &core.NemesisOperation{
+			Name:       "clock",
+			InvokeArgs: randomClockArgs(g.name),
+			RunTime:    runTime,
+		}
+	}
+
+	return ops
+}
+
+func (g clockGenerator) Name() string {
+	return g.name
+}
+
+func randomClockArgs(name string) []string {
+	switch name {
+	case "strobe_clock":
+		return []string{
+			"mode=strobe",
+			fmt.Sprintf("delta=%dms", rand.Intn(10000)+100),
+			fmt.Sprintf("period=%dms", rand.Intn(100)+1),
+		}
+	case "skew_clock":
+		return []string{
+			"mode=skew",
+			fmt.Sprintf("rate=%.2f", 0.5+rand.Float64()*1.5),
+		}
+	default:
+		// Bump the clock up to 30 seconds forward or backward.
+		delta := time.Duration(rand.Int63n(int64(60*time.Second))) - 30*time.Second
+		return []string{
+			"mode=bump",
+			fmt.Sprintf("delta=%s", delta),
+		}
+	}
+}
+
+// NewClockGenerator creates a generator which disturbs the clocks of some random nodes.
+// Name is bump_clock, strobe_clock and skew_clock.
+func NewClockGenerator(name string) core.NemesisGenerator {
+	return clockGenerator{name: name}
+}
+
+func init() {
+	core.RegisterNemesis(&clock{})
+}
@@ -0,0 +1,31 @@
+package nemesis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockArgs(t *testing.T) {
+	opts, err := parseClockArgs([]string{"mode=strobe", "delta=-5s", "period=20ms"})
+	if err != nil {
+		t.Fatalf("parse clock args failed %v", err)
+	}
+
+	if opts.mode != clockStrobe || opts.delta != -5*time.Second || opts.period != 20*time.Millisecond {
+		t.Fatalf("invalid clock options %+v", opts)
+	}
+
+	for _, name := range []string{"bump_clock", "strobe_clock", "skew_clock"} {
+		if _, err = parseClockArgs(randomClockArgs(name)); err != nil {
+			t.Fatalf("generated %s arguments must be valid, but got %v", name, err)
+		}
+	}
+
+	if _, err = parseClockArgs([]string{"mode=jump"}); err == nil {
+		t.Fatal("unknown mode must fail")
+	}
+
+	if _, err = parseClockArgs([]string{"mode=strobe", "period=0s"}); err == nil {
+		t.Fatal("zero strobe period must fail")
+	}
+}
@@ -0,0 +1,301 @@
+package nemesis
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/siddontang/chaos/pkg/core"
+)
+
+// generateGroups returns the operation groups of the generator,
+// nil means the generator is exhausted.
+func generateGroups(g core.NemesisGenerator, nodes []string) [][]*core.NemesisOperation {
+	if cg, ok := g.(core.ConcurrentNemesisGenerator); ok {
+		return cg.GenerateGroups(nodes)
+	}
+
+	// The generators may not handle no node, but the composed ones above
+	// still see the round, so the limit counts it.
+	if len(nodes) == 0 {
+		return [][]*core.NemesisOperation{{}}
+	}
+
+	ops := g.Generate(nodes)
+	if ops == nil {
+		return nil
+	}
+	return [][]*core.NemesisOperation{ops}
+}
+
+// mergeGroups merges the groups into one operation for each node,
+// the first group wins if several groups disturb the same node.
+func mergeGroups(groups [][]*core.NemesisOperation, n int) []*core.NemesisOperation {
+	if groups == nil {
+		return nil
+	}
+
+	ops := make([]*core.NemesisOperation, n)
+	for _, group := range groups {
+		for i, op := range group {
+			if i < n && ops[i] == nil {
+				ops[i] = op
+			}
+		}
+	}
+	return ops
+}
+
+func generatorNames(gens []core.NemesisGenerator, sep string) string {
+	names := make([]string, 0, len(gens))
+	for _, g := range gens {
+		names = append(names, g.Name())
+	}
+	return strings.Join(names, sep)
+}
+
+// concurrentGenerator runs all the generators at the same time.
+type concurrentGenerator struct {
+	gens []core.NemesisGenerator
+}
+
+func (g concurrentGenerator) GenerateGroups(nodes []string) [][]*core.NemesisOperation {
+	var groups [][]*core.NemesisOperation
+	for _, gen := range g.gens {
+		groups = append(groups, generateGroups(gen, nodes)...)
+	}
+	return groups
+}
+
+func (g concurrentGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	return mergeGroups(g.GenerateGroups(nodes), len(nodes))
+}
+
+func (g concurrentGenerator) Name() string {
+	return generatorNames(g.gens, "+")
+}
+
+// NewConcurrentGenerator creates a generator which runs all the generators concurrently,
+// like partitioning the network while killing TiKV.
+func NewConcurrentGenerator(gens ...core.NemesisGenerator) core.NemesisGenerator {
+	return concurrentGenerator{gens: gens}
+}
+
+// weightedGenerator picks a generator randomly by the weights each time.
+type weightedGenerator struct {
+	sync.Mutex
+	gens      []core.NemesisGenerator
+	weights   []int
+	exhausted []bool
+}
+
+func (g *weightedGenerator) GenerateGroups(nodes []string) [][]*core.NemesisOperation {
+	g.Lock()
+	defer g.Unlock()
+
+	for {
+		total := 0
+		for i, w := range g.weights {
+			if !g.exhausted[i] {
+				total += w
+			}
+		}
+		if total <= 0 {
+			return nil
+		}
+
+		n := rand.Intn(total)
+		for i, w := range g.weights {
+			if g.exhausted[i] {
+				continue
+			}
+			if n >= w {
+				n -= w
+				continue
+			}
+
+			if groups := generateGroups(g.gens[i], nodes); groups != nil {
+				return groups
+			}
+			// Pick another one if the chosen generator is exhausted.
+			g.exhausted[i] = true
+			break
+		}
+	}
+}
+
+func (g *weightedGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	return mergeGroups(g.GenerateGroups(nodes), len(nodes))
+}
+
+func (g *weightedGenerator) Name() string {
+	names := make([]string, 0, len(g.gens))
+	for i, gen := range g.gens {
+		names = append(names, fmt.Sprintf("%s:%d", gen.Name(), g.weights[i]))
+	}
+	return strings.Join(names, "|")
+}
+
+// NewWeightedGenerator creates a generator which picks one of the generators
+// randomly by the weights each time. The generator without a weight has weight 1.
+func NewWeightedGenerator(weights []int, gens ...core.NemesisGenerator) core.NemesisGenerator {
+	g := &weightedGenerator{
+		gens:      gens,
+		weights:   make([]int, len(gens)),
+		exhausted: make([]bool, len(gens)),
+	}
+	for i := range gens {
+		g.weights[i] = 1
+		if i < len(weights) && weights[i] >= 0 {
+			g.weights[i] = weights[i]
+		}
+	}
+	return g
+}
+
+// alternateGenerator uses the generators in turn.
+type alternateGenerator struct {
+	sync.Mutex
+	gens []core.NemesisGenerator
+	next int
+}
+
+func (g *alternateGenerator) GenerateGroups(nodes []string) [][]*core.NemesisOperation {
+	g.Lock()
+	defer g.Unlock()
+
+	// Skip the exhausted generators, all exhausted if none generates in a round.
+	for i := 0; i < len(g.gens); i++ {
+		gen := g.gens[g.next]
+		g.next = (g.next + 1) % len(g.gens)
+		if groups := generateGroups(gen, nodes); groups != nil {
+			return groups
+		}
+	}
+	return nil
+}
+
+func (g *alternateGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	return mergeGroups(g.GenerateGroups(nodes), len(nodes))
+}
+
+func (g *alternateGenerator) Name() string {
+	return generatorNames(g.gens, "/")
+}
+
+// NewAlternateGenerator creates a generator which uses the generators in turn.
+func NewAlternateGenerator(gens ...core.NemesisGenerator) core.NemesisGenerator {
+	return &alternateGenerator{gens: gens}
+}
+
+// limitGenerator stops generating after the limit.
+type limitGenerator struct {
+	sync.Mutex
+	gen   core.NemesisGenerator
+	limit int
+	count int
+}
+
+func (g *limitGenerator) GenerateGroups(nodes []string) [][]*core.NemesisOperation {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.count >= g.limit {
+		return nil
+	}
+	g.count++
+	return generateGroups(g.gen, nodes)
+}
+
+func (g *limitGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	return mergeGroups(g.GenerateGroups(nodes), len(nodes))
+}
+
+func (g *limitGenerator) Name() string {
+	return fmt.Sprintf("%s*%d", g.gen.Name(), g.limit)
+}
+
+// NewLimitGenerator creates a generator which only generates n times.
+func NewLimitGenerator(n int, gen core.NemesisGenerator) core.NemesisGenerator {
+	return &limitGenerator{gen: gen, limit: n}
+}
+
+// ParseGenerator parses the generator expression and uses newGenerator to
+// create the generator by name. The operators from the lowest precedence are:
+//
+//	a:3|b  picks a or b randomly, a is 3 times more likely than b
+//	a/b    uses a and b in turn
+//	a+b    runs a and b concurrently
+//	a*3    only runs a 3 times
+//
+// E.g. partition_halves+random_kill*5 runs the halves partition and kills
+// TiKV at the same time, 5 times at most for the kill.
+func ParseGenerator(expr string, newGenerator func(name string) (core.NemesisGenerator, error)) (core.NemesisGenerator, error) {
+	expr = strings.TrimSpace(expr)
+
+	if items := strings.Split(expr, "|"); len(items) > 1 {
+		gens := make([]core.NemesisGenerator, 0, len(items))
+		weights := make([]int, 0, len(items))
+		for _, item := range items {
+			weight := 1
+			if i := strings.LastIndex(item, ":"); i >= 0 {
+				var err error
+				if weight, err = strconv.Atoi(strings.TrimSpace(item[i+1:])); err != nil || weight < 0 {
+					return nil, fmt.Errorf("invalid weight in %s", item)
+				}
+				item = item[:i]
+			}
+
+			g, err := ParseGenerator(item, newGenerator)
+			if err != nil {
+				return nil, err
+			}
+			gens = append(gens, g)
+			weights = append(weights, weight)
+		}
+		return NewWeightedGenerator(weights, gens...), nil
+	}
+
+	for _, op := range []struct {
+		sep     string
+		compose func(gens ...core.NemesisGenerator) core.NemesisGenerator
+	}{
+		{"/", NewAlternateGenerator},
+		{"+", NewConcurrentGenerator},
+	} {
+		items := strings.Split(expr, op.sep)
+		if len(items) == 1 {
+			continue
+		}
+
+		gens := make([]core.NemesisGenerator, 0, len(items))
+		for _, item := range items {
+			g, err := ParseGenerator(item, newGenerator)
+			if err != nil {
+				return nil, err
+			}
+			gens = append(gens, g)
+		}
+		return op.compose(gens...), nil
+	}
+
+	if i := strings.LastIndex(expr, "*"); i >= 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(expr[i+1:]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit in %s", expr)
+		}
+
+		g, err := ParseGenerator(expr[:i], newGenerator)
+		if err != nil {
+			return nil, err
+		}
+		return NewLimitGenerator(n, g), nil
+	}
+
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("empty nemesis generator")
+	}
+	return newGenerator(expr)
+}
@@ -0,0 +1,90 @@
+package nemesis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/siddontang/chaos/pkg/core"
+)
+
+// nodeGenerator runs the nemesis on the node.
+type nodeGenerator struct {
+	name string
+	node int
+}
+
+func (g nodeGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	ops[g.node] = &core.NemesisOperation{Name: g.name}
+	return ops
+}
+
+func (g nodeGenerator) Name() string {
+	return g.name
+}
+
+func newNodeGenerator(name string) (core.NemesisGenerator, error) {
+	switch name {
+	case "a":
+		return nodeGenerator{name: name, node: 0}, nil
+	case "b":
+		return nodeGenerator{name: name, node: 0}, nil
+	case "c":
+		return nodeGenerator{name: name, node: 1}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator %s", name)
+	}
+}
+
+func TestComposeGenerator(t *testing.T) {
+	nodes := []string{"n1", "n2"}
+
+	g, err := ParseGenerator("a+c*2", newNodeGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name() != "a+c*2" {
+		t.Fatalf("invalid name %s", g.Name())
+	}
+
+	for i := 0; i < 3; i++ {
+		groups := generateGroups(g, nodes)
+		if i < 2 && (len(groups) != 2 || groups[0][0].Name != "a" || groups[1][1].Name != "c") {
+			t.Fatalf("pass %d must run a and c concurrently, but got %v", i, groups)
+		} else if i == 2 && len(groups) != 1 {
+			t.Fatalf("c must stop after 2 times, but got %v", groups)
+		}
+	}
+
+	g, err = ParseGenerator("a/b*1", newNodeGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for i := 0; i < 3; i++ {
+		names = append(names, g.Generate(nodes)[0].Name)
+	}
+	if fmt.Sprint(names) != "[a b a]" {
+		t.Fatalf("a and b must alternate until b is exhausted, but got %v", names)
+	}
+
+	g, err = ParseGenerator("a:0|b:1", newNodeGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if name := g.Generate(nodes)[0].Name; name != "b" {
+			t.Fatalf("zero weight a must not be picked, but got %s", name)
+		}
+	}
+
+	if ops := NewLimitGenerator(0, g).Generate(nodes); ops != nil {
+		t.Fatalf("exhausted generator must generate nil, but got %v", ops)
+	}
+
+	for _, expr := range []string{"a|b:x", "a*x", "a+", "d"} {
+		if _, err = ParseGenerator(expr, newNodeGenerator); err == nil {
+			t.Fatalf("%s must fail", expr)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util/disk"
+	"github.com/siddontang/chaos/tidb"
+)
+
+// Disk nemesis names.
+const (
+	diskFill     = "disk_fill"
+	diskReadOnly = "disk_readonly"
+	diskDelay    = "disk_delay"
+	diskError    = "disk_error"
+)
+
+type diskOptions struct {
+	dir     string
+	reserve int64
+	device  disk.Device
+	delay   time.Duration
+	up      time.Duration
+	down    time.Duration
+}
+
+// parseDiskArgs parses arguments like dir=/opt/tidb/tikv, reserve=1048576,
+// device=chaos-tikv, image=/var/lib/chaos/tikv.img, delay=100ms, up=1s and down=1s.
+func parseDiskArgs(args []string) (diskOptions, error) {
+	opts := diskOptions{
+		delay: 100 * time.Millisecond,
+		up:    time.Second,
+		down:  time.Second,
+	}
+
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return opts, err
+		}
+
+		switch key {
+		case "dir":
+			opts.dir = value
+			opts.device.Dir = value
+		case "reserve":
+			opts.reserve, err = strconv.ParseInt(value, 10, 64)
+		case "device":
+			opts.device.Name = value
+		case "image":
+			opts.device.Image = value
+		case "delay":
+			opts.delay, err = time.ParseDuration(value)
+		case "up":
+			opts.up, err = time.ParseDuration(value)
+		case "down":
+			opts.down, err = time.ParseDuration(value)
+		default:
+			err = fmt.Errorf("unknown disk argument %s", key)
+		}
+
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// diskFault injects storage failures, fill works on the directory which is not on
+// the root filesystem, readonly uses the device-mapper device if the directory is
+// on it, delay and error need the directory on a device-mapper device.
+type diskFault struct {
+	name string
+}
+
+func (n diskFault) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseDiskArgs(args)
+	if err != nil {
+		return err
+	}
+
+	switch n.name {
+	case diskFill:
+		if !opts.device.Exists() {
+			// Filling the root filesystem breaks the whole node, and the other nodes
+			// too if they run in containers on the same host.
+			mountPoint, err := disk.MountPoint(opts.dir)
+			if err != nil {
+				return err
+			}
+			if mountPoint == "/" {
+				return fmt.Errorf("%s is on the root filesystem, %s needs the data on a dedicated device or mount", opts.dir, n.name)
+			}
+		}
+		return disk.Fill(ctx, opts.dir, opts.reserve)
+	case diskReadOnly:
+		if opts.device.Exists() {
+			return opts.device.ReadOnly(ctx)
+		}
+		// Without the device, only the files opened later are read only.
+		return disk.ReadOnly(ctx, opts.dir)
+	case diskDelay, diskError:
+		if !opts.device.Exists() {
+			return fmt.Errorf("device %s doesn't exist, %s needs the data on a device-mapper device", opts.device.Name, n.name)
+		}
+		if n.name == diskDelay {
+			return opts.device.Delay(ctx, opts.delay)
+		}
+		return opts.device.Flakey(ctx, opts.up, opts.down)
+	default:
+		return fmt.Errorf("unknown disk nemesis %s", n.name)
+	}
+}
+
+func (n diskFault) Recover(ctx context.Context, args ...string) error {
+	opts, err := parseDiskArgs(args)
+	if err != nil {
+		return err
+	}
+
+	switch n.name {
+	case diskFill:
+		return disk.Unfill(opts.dir)
+	case diskReadOnly:
+		if opts.device.Exists() {
+			return opts.device.Restore(ctx)
+		}
+		return disk.ReadWrite(ctx, opts.dir)
+	case diskDelay, diskError:
+		return opts.device.Restore(ctx)
+	default:
+		return fmt.Errorf("unknown disk nemesis %s", n.name)
+	}
+}
+
+func (n diskFault) Name() string {
+	return n.name
+}
+
+type diskGenerator struct {
+	name string
+}
+
+// Generate injects the disk fault to TiKV on a random node.
+func (g diskGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	if len(nodes) == 0 {
+		return ops
+	}
+
+	args := []string{
+		fmt.Sprintf("dir=%s", tidb.TiKVDataDir),
+		fmt.Sprintf("device=%s", tidb.TiKVDevice.Name),
+		fmt.Sprintf("image=%s", tidb.TiKVDevice.Image),
+	}
+	switch g.name {
+	case diskDelay:
+		args = append(args, fmt.Sprintf("delay=%dms", rand.Intn(500)+10))
+	case diskError:
+		args = append(args, fmt.Sprintf("up=%ds", rand.Intn(3)), fmt.Sprintf("down=%ds", rand.Intn(3)+1))
+	}
+
+	ops[rand.Intn(len(nodes))] = &core.NemesisOperation{
+		Name:        g.name,
+		InvokeArgs:  args,
+		RecoverArgs: args,
+		RunTime:     time.Second * time.Duration(rand.Intn(10)+1),
+	}
+	return ops
+}
+
+func (g diskGenerator) Name() string {
+	return g.name
+}
+
+// NewDiskGenerator creates a generator which injects disk faults to TiKV.
+// Name is disk_fill, disk_readonly, disk_delay and disk_error.
+func NewDiskGenerator(name string) core.NemesisGenerator {
+	return diskGenerator{name: name}
+}
+
+func init() {
+	for _, name := range []string{diskFill, diskReadOnly, diskDelay, diskError} {
+		core.RegisterNemesis(diskFault{name: name})
+	}
+}
@@ -0,0 +1,30 @@
+package nemesis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDiskArgs(t *testing.T) {
+	ops := NewDiskGenerator(diskDelay).Generate([]string{"n1"})
+	opts, err := parseDiskArgs(ops[0].InvokeArgs)
+	if err != nil {
+		t.Fatalf("parse disk args failed %v", err)
+	}
+
+	if opts.dir != "/opt/tidb/tikv" || opts.device.Dir != opts.dir {
+		t.Fatalf("invalid disk directory %+v", opts)
+	}
+
+	if opts.device.Name != "chaos-tikv" || len(opts.device.Image) == 0 {
+		t.Fatalf("invalid disk device %+v", opts.device)
+	}
+
+	if opts.delay < 10*time.Millisecond {
+		t.Fatalf("invalid disk delay %v", opts.delay)
+	}
+
+	if _, err = parseDiskArgs([]string{"reserve=1MB"}); err == nil {
+		t.Fatal("invalid reserve must fail")
+	}
+}
@@ -1,6 +1,8 @@
 package nemesis
 
 import (
+	"context"
+	"log"
 	"math/rand"
 	"time"
 
@@ -10,8 +12,9 @@ import (
 )
 
 type killGenerator struct {
-	db   string
-	name string
+	db      string
+	service string
+	name    string
 }
 
 func (g killGenerator) Generate(nodes []string) []*core.NemesisOperation {
@@ -27,14 +30,15 @@ func (g killGenerator) Generate(nodes []string) []*core.NemesisOperation {
 		n = 1
 	}
 
-	return killNodes(g.db, nodes, n)
+	return serviceNodes("kill", g.db, g.service, nodes, n)
 }
 
 func (g killGenerator) Name() string {
 	return g.name
 }
 
-func killNodes(db string, nodes []string, n int) []*core.NemesisOperation {
+// serviceNodes runs the nemesis on the service of n random nodes.
+func serviceNodes(nemesis string, db string, service string, nodes []string, n int) []*core.NemesisOperation {
 	ops := make([]*core.NemesisOperation, len(nodes))
 
 	// randomly shuffle the indecies and get the first n nodes to be partitioned.
@@ -42,10 +46,61 @@ func killNodes(db string, nodes []string, n int) []*core.NemesisOperation {
 
 	for i := 0; i < n; i++ {
 		ops[indices[i]] = &core.NemesisOperation{
-			Name: "kill",
-			//TODO: Only kill kv now
-			InvokeArgs:  []string{db, tidb.SERVICE_TIKV},
-			RecoverArgs: []string{db},
+			Name:        nemesis,
+			InvokeArgs:  []string{db, service},
+			RecoverArgs: []string{db, service},
+			RunTime:     time.Second * time.Duration(rand.Intn(10)+1),
+		}
+	}
+
+	return ops
+}
+
+// roleKillGenerator kills the service on a random node which plays
+// the role in the live cluster.
+type roleKillGenerator struct {
+	db      string
+	name    string
+	service string
+	s       Selector
+}
+
+func (g roleKillGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	candidates, err := g.s.Select(ctx, nodes)
+	if err != nil {
+		log.Printf("find the nodes for %s failed %v", g.name, err)
+		return make([]*core.NemesisOperation, len(nodes))
+	}
+
+	if len(candidates) == 0 {
+		log.Printf("no node in %v plays the role for %s", nodes, g.name)
+		return make([]*core.NemesisOperation, len(nodes))
+	}
+
+	target := candidates[rand.Intn(len(candidates))]
+	return targetNodes("kill", g.db, g.service, nodes, []string{target})
+}
+
+func (g roleKillGenerator) Name() string {
+	return g.name
+}
+
+// targetNodes runs the nemesis on the service of the target nodes.
+func targetNodes(nemesis string, db string, service string, nodes []string, targets []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	for _, target := range targets {
+		i := indexOf(nodes, target)
+		if i < 0 {
+			continue
+		}
+
+		ops[i] = &core.NemesisOperation{
+			Name:        nemesis,
+			InvokeArgs:  []string{db, service},
+			RecoverArgs: []string{db, service},
 			RunTime:     time.Second * time.Duration(rand.Intn(10)+1),
 		}
 	}
@@ -53,10 +108,78 @@ func killNodes(db string, nodes []string, n int) []*core.NemesisOperation {
 	return ops
 }
 
+func indexOf(nodes []string, node string) int {
+	for i, n := range nodes {
+		if n == node {
+			return i
+		}
+	}
+	return -1
+}
+
 // NewKillGenerator creates a generator.
-// Name is random_kill, minor_kill, major_kill, and all_kill.
-func NewKillGenerator(db string, name string) core.NemesisGenerator {
-	return killGenerator{db: db, name: name}
+// Name is random_kill, minor_kill, major_kill, and all_kill which kill the service on random nodes,
+// or kill_pd_leader, kill_random_pd, kill_random_tikv, kill_random_tidb and
+// kill_tikv_region_leader which kill the service of the role on the node playing
+// it in the live cluster, the service argument is ignored for them.
+func NewKillGenerator(db string, service string, name string) core.NemesisGenerator {
+	switch name {
+	case "kill_pd_leader":
+		return roleKillGenerator{db: db, name: name, service: tidb.SERVICE_PD, s: NewRoleSelector(rolePDLeader)}
+	case "kill_random_pd":
+		return roleKillGenerator{db: db, name: name, service: tidb.SERVICE_PD, s: NewRoleSelector(rolePD)}
+	case "kill_random_tikv":
+		return roleKillGenerator{db: db, name: name, service: tidb.SERVICE_TIKV, s: NewRoleSelector(roleTiKV)}
+	case "kill_random_tidb":
+		return roleKillGenerator{db: db, name: name, service: tidb.SERVICE_TIDB, s: NewRoleSelector(roleTiDB)}
+	case "kill_tikv_region_leader":
+		return NewRegionLeaderKillGenerator(db, 0)
+	default:
+		return killGenerator{db: db, service: service, name: name}
+	}
+}
+
+// NewRegionLeaderKillGenerator creates a generator which kills the TiKV holding
+// the leader of the region, zero region ID means a random region.
+func NewRegionLeaderKillGenerator(db string, regionID uint64) core.NemesisGenerator {
+	return roleKillGenerator{
+		db:      db,
+		name:    "kill_tikv_region_leader",
+		service: tidb.SERVICE_TIKV,
+		s:       NewRegionLeaderSelector(regionID),
+	}
+}
+
+type pauseGenerator struct {
+	db      string
+	service string
+	name    string
+}
+
+func (g pauseGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	n := 1
+	switch g.name {
+	case "minor_pause":
+		n = int(math.Floor(float64(len(nodes)) / float64(2)))
+	case "major_pause":
+		n = int(math.Ceil(float64(len(nodes)) / float64(2)))
+	case "all_pause":
+		n = len(nodes)
+	default:
+		n = 1
+	}
+
+	return serviceNodes("pause", g.db, g.service, nodes, n)
+}
+
+func (g pauseGenerator) Name() string {
+	return g.name
+}
+
+// NewPauseGenerator creates a generator which pauses the service.
+// Name is random_pause, minor_pause, major_pause, and all_pause.
+func NewPauseGenerator(db string, service string, name string) core.NemesisGenerator {
+	return pauseGenerator{db: db, service: service, name: name}
 }
 
 type dropGenerator struct {
@@ -105,7 +228,7 @@ func partitionNodes(nodes []string, n int) []*core.NemesisOperation {
 }
 
 func shuffleIndices(n int) []int {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := rand.New(rand.NewSource(rand.Int63()))
 	indices := make([]int, n)
 	for i := 0; i < n; i++ {
 		indices[i] = i
@@ -0,0 +1,27 @@
+package nemesis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKillGenerator(t *testing.T) {
+	ops := NewKillGenerator("tidb", "pd", "minor_kill").Generate(testNodes)
+
+	killed := 0
+	for _, op := range ops {
+		if op == nil {
+			continue
+		}
+		killed++
+
+		// Recover must start the same service which is killed.
+		if strings.Join(op.InvokeArgs, ",") != "tidb,pd" || strings.Join(op.RecoverArgs, ",") != "tidb,pd" {
+			t.Fatalf("invalid kill args %v %v", op.InvokeArgs, op.RecoverArgs)
+		}
+	}
+
+	if killed != 2 {
+		t.Fatalf("must kill 2 nodes, but got %d", killed)
+	}
+}
@@ -0,0 +1,190 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/tidb"
+)
+
+type memberOptions struct {
+	db      string
+	service string
+	// join adds the service to the cluster, or else removes it.
+	join bool
+}
+
+// parseMemberArgs parses arguments like db=tidb, service=tikv and action=join|leave.
+func parseMemberArgs(args []string) (memberOptions, error) {
+	var opts memberOptions
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return opts, err
+		}
+
+		switch key {
+		case "db":
+			opts.db = value
+		case "service":
+			opts.service = value
+		case "action":
+			if value != "join" && value != "leave" {
+				err = fmt.Errorf("unknown member action %s", value)
+			}
+			opts.join = value == "join"
+		default:
+			err = fmt.Errorf("unknown member argument %s", key)
+		}
+
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	if core.GetDB(opts.db) == nil {
+		return opts, fmt.Errorf("db %s is not registered", opts.db)
+	}
+
+	return opts, nil
+}
+
+// member changes the membership of the service on the node,
+// and does the inverse action when recovering to restore the original membership.
+type member struct{}
+
+func (member) change(ctx context.Context, opts memberOptions) error {
+	db := core.GetDB(opts.db)
+	if opts.join {
+		if err := db.Join(ctx, opts.service); err != nil {
+			return err
+		}
+		return waitReady(ctx, db, opts.service)
+	}
+	return db.Leave(ctx, opts.service)
+}
+
+func (n member) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseMemberArgs(args)
+	if err != nil {
+		return err
+	}
+	return n.change(ctx, opts)
+}
+
+func (n member) Recover(ctx context.Context, args ...string) error {
+	opts, err := parseMemberArgs(args)
+	if err != nil {
+		return err
+	}
+	opts.join = !opts.join
+	return n.change(ctx, opts)
+}
+
+func (member) Name() string {
+	return "member"
+}
+
+type memberGenerator struct {
+	db      string
+	name    string
+	cluster *tidb.Cluster
+}
+
+// Generate removes a TiKV store for tikv_membership, or adds a PD member
+// on the node without PD for pd_membership.
+func (g memberGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		service    string
+		action     string
+		candidates []string
+	)
+	switch g.name {
+	case "tikv_membership":
+		kvs, err := g.cluster.TiKVNodes(ctx)
+		if err != nil {
+			log.Printf("find the nodes for %s failed %v", g.name, err)
+			return ops
+		}
+		// PD can't move the regions away if the stores are fewer than the replicas.
+		replicas, err := g.cluster.MaxReplicas(ctx)
+		if err != nil {
+			log.Printf("get the max replicas for %s failed %v", g.name, err)
+			return ops
+		}
+		if len(kvs) <= replicas {
+			log.Printf("only %d tikv stores for %d replicas, skip %s", len(kvs), replicas, g.name)
+			return ops
+		}
+
+		service, action = tidb.SERVICE_TIKV, "leave"
+		for _, node := range kvs {
+			if indexOf(nodes, node) >= 0 {
+				candidates = append(candidates, node)
+			}
+		}
+	case "pd_membership":
+		pds, err := g.cluster.PDNodes(ctx)
+		if err != nil {
+			log.Printf("find the nodes for %s failed %v", g.name, err)
+			return ops
+		}
+
+		service, action = tidb.SERVICE_PD, "join"
+		for _, node := range nodes {
+			if indexOf(pds, node) < 0 {
+				candidates = append(candidates, node)
+			}
+		}
+	default:
+		log.Printf("unknown membership generator %s", g.name)
+		return ops
+	}
+
+	if len(candidates) == 0 {
+		log.Printf("no node in %v for %s", nodes, g.name)
+		return ops
+	}
+
+	target := candidates[rand.Intn(len(candidates))]
+	args := []string{
+		fmt.Sprintf("db=%s", g.db),
+		fmt.Sprintf("service=%s", service),
+		fmt.Sprintf("action=%s", action),
+	}
+	ops[indexOf(nodes, target)] = &core.NemesisOperation{
+		Name:        "member",
+		InvokeArgs:  args,
+		RecoverArgs: args,
+		// Give PD some time to move the regions or to reconfigure.
+		RunTime: time.Second * time.Duration(rand.Intn(30)+10),
+	}
+	return ops
+}
+
+func (g memberGenerator) Name() string {
+	return g.name
+}
+
+// NewMemberGenerator creates a generator which changes the cluster membership.
+// Name is tikv_membership and pd_membership.
+func NewMemberGenerator(db string, name string) core.NemesisGenerator {
+	return memberGenerator{db: db, name: name, cluster: tidb.NewCluster(tidb.PDAddr)}
+}
+
+func init() {
+	core.RegisterNemesis(member{})
+}
@@ -0,0 +1,24 @@
+package nemesis
+
+import (
+	"testing"
+)
+
+func TestParseMemberArgs(t *testing.T) {
+	opts, err := parseMemberArgs([]string{"db=noop", "service=tikv", "action=leave"})
+	if err != nil {
+		t.Fatalf("parse member args failed %v", err)
+	}
+
+	if opts.service != "tikv" || opts.join {
+		t.Fatalf("invalid member options %+v", opts)
+	}
+
+	if _, err = parseMemberArgs([]string{"db=noop", "action=add"}); err == nil {
+		t.Fatal("unknown action must fail")
+	}
+
+	if _, err = parseMemberArgs([]string{"db=unknown", "action=join"}); err == nil {
+		t.Fatal("unregistered db must fail")
+	}
+}
@@ -2,11 +2,50 @@ package nemesis
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/siddontang/chaos/pkg/core"
 	"github.com/siddontang/chaos/pkg/util/net"
 )
 
+// readyTimeout is how long the restarted service may take to be ready.
+const readyTimeout = time.Minute
+
+// waitReady polls the restarted service until it is ready or timed out, so the
+// nemesis recovers the cluster fully. It fails at once if the service exits,
+// like crashing on startup.
+func waitReady(ctx context.Context, db core.DB, service string) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	for {
+		if !db.IsRunning(ctx, service) {
+			return fmt.Errorf("%s exits before ready", service)
+		}
+
+		err := db.Ready(ctx, service)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait %s ready failed %v", service, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// startService starts the service and waits for it to be ready.
+func startService(ctx context.Context, db core.DB, service string) error {
+	if err := db.Start(ctx, service); err != nil {
+		return err
+	}
+	return waitReady(ctx, db, service)
+}
+
 type kill struct{}
 
 func (kill) Invoke(ctx context.Context, args ...string) error {
@@ -18,13 +57,31 @@ func (kill) Invoke(ctx context.Context, args ...string) error {
 func (kill) Recover(ctx context.Context, args ...string) error {
 	db := core.GetDB(args[0])
 	service := args[1]
-	return db.Start(ctx, service)
+	return startService(ctx, db, service)
 }
 
 func (kill) Name() string {
 	return "kill"
 }
 
+type pause struct{}
+
+func (pause) Invoke(ctx context.Context, args ...string) error {
+	db := core.GetDB(args[0])
+	service := args[1]
+	return db.Pause(ctx, service)
+}
+
+func (pause) Recover(ctx context.Context, args ...string) error {
+	db := core.GetDB(args[0])
+	service := args[1]
+	return db.Resume(ctx, service)
+}
+
+func (pause) Name() string {
+	return "pause"
+}
+
 type drop struct {
 	t net.IPTables
 }
@@ -52,7 +109,17 @@ func (drop) Name() string {
 	return "drop"
 }
 
+// splitArg splits the nemesis argument in the key=value format.
+func splitArg(arg string) (string, string, error) {
+	seps := strings.SplitN(arg, "=", 2)
+	if len(seps) != 2 {
+		return "", "", fmt.Errorf("invalid argument %s, must be key=value", arg)
+	}
+	return seps[0], seps[1], nil
+}
+
 func init() {
 	core.RegisterNemesis(kill{})
+	core.RegisterNemesis(pause{})
 	core.RegisterNemesis(drop{})
 }
@@ -0,0 +1,175 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util/net"
+)
+
+// Netem nemesis names.
+const (
+	netemDelay     = "delay"
+	netemLoss      = "loss"
+	netemDuplicate = "duplicate"
+	netemReorder   = "reorder"
+	netemCorrupt   = "corrupt"
+)
+
+// defaultNetemOptions returns the options used by the netem nemesis
+// if no argument overwrites them.
+func defaultNetemOptions(name string) net.NetemOptions {
+	switch name {
+	case netemDelay:
+		opts := net.DefaultSlowOptions()
+		return net.NetemOptions{
+			Delay:        opts.Mean,
+			Jitter:       opts.Variance,
+			Distribution: opts.Distribution,
+		}
+	case netemLoss:
+		return net.NetemOptions{Loss: 20, Correlation: 75}
+	case netemDuplicate:
+		return net.NetemOptions{Duplicate: 10}
+	case netemReorder:
+		return net.NetemOptions{Delay: 10 * time.Millisecond, Reorder: 25, Correlation: 50}
+	case netemCorrupt:
+		return net.NetemOptions{Corrupt: 5}
+	default:
+		return net.NetemOptions{}
+	}
+}
+
+// parseNetemArgs overwrites the default options of the nemesis with
+// arguments like delay=100ms, jitter=10ms, distribution=normal, loss=20,
+// duplicate=10, reorder=25, corrupt=5 and correlation=50.
+func parseNetemArgs(name string, args []string) (net.NetemOptions, error) {
+	opts := defaultNetemOptions(name)
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return opts, err
+		}
+
+		switch key {
+		case "delay":
+			opts.Delay, err = time.ParseDuration(value)
+		case "jitter":
+			opts.Jitter, err = time.ParseDuration(value)
+		case "distribution":
+			opts.Distribution = value
+		case "loss":
+			opts.Loss, err = parsePercent(value)
+		case "duplicate":
+			opts.Duplicate, err = parsePercent(value)
+		case "reorder":
+			opts.Reorder, err = parsePercent(value)
+		case "corrupt":
+			opts.Corrupt, err = parsePercent(value)
+		case "correlation":
+			opts.Correlation, err = parsePercent(value)
+		default:
+			err = fmt.Errorf("unknown netem argument %s", key)
+		}
+
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+func parsePercent(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+}
+
+// netem shapes all the network packets of the node.
+type netem struct {
+	name string
+	t    net.IPTables
+}
+
+func (n netem) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseNetemArgs(n.name, args)
+	if err != nil {
+		return err
+	}
+
+	return n.t.Netem(ctx, opts)
+}
+
+func (n netem) Recover(ctx context.Context, args ...string) error {
+	return n.t.Fast(ctx)
+}
+
+func (n netem) Name() string {
+	return n.name
+}
+
+type netemGenerator struct {
+	name string
+}
+
+func (g netemGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	args := randomNetemArgs(g.name)
+	runTime := time.Second * time.Duration(rand.Intn(10)+1)
+
+	for i := range nodes {
+		ops[i] = &core.NemesisOperation{
+			Name:       g.name,
+			InvokeArgs: args,
+			RunTime:    runTime,
+		}
+	}
+
+	return ops
+}
+
+func (g netemGenerator) Name() string {
+	return g.name
+}
+
+func randomNetemArgs(name string) []string {
+	switch name {
+	case netemDelay:
+		delay := rand.Intn(450) + 50
+		return []string{
+			fmt.Sprintf("delay=%dms", delay),
+			fmt.Sprintf("jitter=%dms", delay/5),
+			"distribution=normal",
+		}
+	case netemLoss:
+		return []string{fmt.Sprintf("loss=%d", rand.Intn(25)+5), "correlation=75"}
+	case netemDuplicate:
+		return []string{fmt.Sprintf("duplicate=%d", rand.Intn(20)+5)}
+	case netemReorder:
+		return []string{"delay=10ms", fmt.Sprintf("reorder=%d", rand.Intn(40)+10), "correlation=50"}
+	case netemCorrupt:
+		return []string{fmt.Sprintf("corrupt=%d", rand.Intn(10)+1)}
+	default:
+		return nil
+	}
+}
+
+// NewNetemGenerator creates a generator which shapes the network on all the nodes.
+// Name is delay, loss, duplicate, reorder and corrupt.
+func NewNetemGenerator(name string) core.NemesisGenerator {
+	return netemGenerator{name: name}
+}
+
+func init() {
+	for _, name := range []string{netemDelay, netemLoss, netemDuplicate, netemReorder, netemCorrupt} {
+		core.RegisterNemesis(netem{name: name})
+	}
+}
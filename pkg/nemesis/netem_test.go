@@ -0,0 +1,35 @@
+package nemesis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNetemArgs(t *testing.T) {
+	opts, err := parseNetemArgs(netemDelay, []string{"delay=200ms", "distribution=pareto"})
+	if err != nil {
+		t.Fatalf("parse netem args failed %v", err)
+	}
+
+	if opts.Delay != 200*time.Millisecond || opts.Distribution != "pareto" {
+		t.Fatalf("arguments must overwrite the default options, but got %+v", opts)
+	}
+
+	if opts.Jitter != 10*time.Millisecond {
+		t.Fatalf("jitter must be the default, but got %v", opts.Jitter)
+	}
+
+	if opts, err = parseNetemArgs(netemLoss, []string{"loss=5%"}); err != nil {
+		t.Fatalf("parse netem args failed %v", err)
+	} else if opts.Loss != 5 || opts.Correlation != 75 {
+		t.Fatalf("invalid loss options %+v", opts)
+	}
+
+	if _, err = parseNetemArgs(netemLoss, []string{"loss"}); err == nil {
+		t.Fatal("argument without value must fail")
+	}
+
+	if _, err = parseNetemArgs(netemLoss, []string{"rate=1mbit"}); err == nil {
+		t.Fatal("unknown argument must fail")
+	}
+}
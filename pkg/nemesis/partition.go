@@ -0,0 +1,153 @@
+package nemesis
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+)
+
+// grudge maps a node to the nodes whose traffic it should drop.
+type grudge map[string][]string
+
+type partitionGenerator struct {
+	name string
+}
+
+func (g partitionGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	shuffled := shuffleNodes(nodes)
+
+	var gr grudge
+	switch g.name {
+	case "partition_random_node":
+		gr = isolateOneGrudge(shuffled)
+	case "partition_bridge":
+		gr = bridgeGrudge(shuffled)
+	case "partition_ring":
+		gr = ringGrudge(shuffled)
+	default:
+		gr = halvesGrudge(shuffled)
+	}
+
+	return grudgeOps(nodes, gr)
+}
+
+func (g partitionGenerator) Name() string {
+	return g.name
+}
+
+// NewPartitionGenerator creates a generator which cuts the network.
+// Name is partition_halves, partition_random_node, partition_bridge and partition_ring.
+func NewPartitionGenerator(name string) core.NemesisGenerator {
+	return partitionGenerator{name: name}
+}
+
+// grudgeOps assigns every node a drop operation for the nodes it holds
+// a grudge against. All the nodes share the same run time so that the
+// partition heals at the same time.
+func grudgeOps(nodes []string, gr grudge) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	runTime := time.Second * time.Duration(rand.Intn(10)+1)
+
+	for i, node := range nodes {
+		dropNodes := gr[node]
+		if len(dropNodes) == 0 {
+			continue
+		}
+
+		ops[i] = &core.NemesisOperation{
+			Name:       "drop",
+			InvokeArgs: dropNodes,
+			RunTime:    runTime,
+		}
+	}
+
+	return ops
+}
+
+// completeGrudge makes every node drop the traffic from all the nodes
+// which are not in its own component.
+func completeGrudge(components ...[]string) grudge {
+	gr := grudge{}
+	for i, component := range components {
+		for j, other := range components {
+			if i == j {
+				continue
+			}
+
+			for _, node := range component {
+				gr[node] = append(gr[node], other...)
+			}
+		}
+	}
+	return gr
+}
+
+// halvesGrudge cuts the nodes into a minority half and a majority half.
+func halvesGrudge(nodes []string) grudge {
+	if len(nodes) < 2 {
+		return grudge{}
+	}
+
+	m := len(nodes) / 2
+	return completeGrudge(nodes[:m], nodes[m:])
+}
+
+// isolateOneGrudge isolates the first node from all the others.
+func isolateOneGrudge(nodes []string) grudge {
+	if len(nodes) < 2 {
+		return grudge{}
+	}
+
+	return completeGrudge(nodes[:1], nodes[1:])
+}
+
+// bridgeGrudge cuts the nodes into two halves which still share one node,
+// the bridge node can talk to both halves.
+func bridgeGrudge(nodes []string) grudge {
+	if len(nodes) < 3 {
+		return grudge{}
+	}
+
+	m := len(nodes) / 2
+	return completeGrudge(nodes[:m], nodes[m+1:])
+}
+
+// ringGrudge arranges the nodes in a ring where every node can only see
+// a majority made up of itself and its nearest neighbours. Every node sees
+// a majority, but no two adjacent nodes see the same one.
+func ringGrudge(nodes []string) grudge {
+	n := len(nodes)
+	gr := grudge{}
+	if n < 3 {
+		return gr
+	}
+
+	majority := n/2 + 1
+	left := (majority - 1) / 2
+	right := majority - 1 - left
+
+	for i, node := range nodes {
+		visible := map[int]bool{}
+		for d := -left; d <= right; d++ {
+			visible[(i+d+n)%n] = true
+		}
+
+		for j, other := range nodes {
+			if !visible[j] {
+				gr[node] = append(gr[node], other)
+			}
+		}
+	}
+
+	return gr
+}
+
+func shuffleNodes(nodes []string) []string {
+	indices := shuffleIndices(len(nodes))
+	shuffled := make([]string, len(nodes))
+	for i, index := range indices {
+		shuffled[i] = nodes[index]
+	}
+	return shuffled
+}
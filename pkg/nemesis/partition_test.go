@@ -0,0 +1,85 @@
+package nemesis
+
+import (
+	"sort"
+	"testing"
+)
+
+var testNodes = []string{"n1", "n2", "n3", "n4", "n5"}
+
+func checkGrudge(t *testing.T, gr grudge, expected map[string][]string) {
+	for _, node := range testNodes {
+		got := append([]string{}, gr[node]...)
+		want := expected[node]
+		sort.Strings(got)
+		sort.Strings(want)
+
+		if len(got) != len(want) {
+			t.Fatalf("node %s must drop %v, but got %v", node, want, got)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("node %s must drop %v, but got %v", node, want, got)
+			}
+		}
+	}
+}
+
+func TestHalvesGrudge(t *testing.T) {
+	checkGrudge(t, halvesGrudge(testNodes), map[string][]string{
+		"n1": {"n3", "n4", "n5"},
+		"n2": {"n3", "n4", "n5"},
+		"n3": {"n1", "n2"},
+		"n4": {"n1", "n2"},
+		"n5": {"n1", "n2"},
+	})
+}
+
+func TestIsolateOneGrudge(t *testing.T) {
+	checkGrudge(t, isolateOneGrudge(testNodes), map[string][]string{
+		"n1": {"n2", "n3", "n4", "n5"},
+		"n2": {"n1"},
+		"n3": {"n1"},
+		"n4": {"n1"},
+		"n5": {"n1"},
+	})
+}
+
+func TestBridgeGrudge(t *testing.T) {
+	checkGrudge(t, bridgeGrudge(testNodes), map[string][]string{
+		"n1": {"n4", "n5"},
+		"n2": {"n4", "n5"},
+		"n4": {"n1", "n2"},
+		"n5": {"n1", "n2"},
+	})
+}
+
+func TestRingGrudge(t *testing.T) {
+	checkGrudge(t, ringGrudge(testNodes), map[string][]string{
+		"n1": {"n3", "n4"},
+		"n2": {"n4", "n5"},
+		"n3": {"n5", "n1"},
+		"n4": {"n1", "n2"},
+		"n5": {"n2", "n3"},
+	})
+}
+
+func TestGrudgeOps(t *testing.T) {
+	ops := grudgeOps(testNodes, bridgeGrudge(testNodes))
+	if len(ops) != len(testNodes) {
+		t.Fatalf("must have %d operations, but got %d", len(testNodes), len(ops))
+	}
+
+	if ops[2] != nil {
+		t.Fatal("bridge node must not drop any traffic")
+	}
+
+	for _, i := range []int{0, 1, 3, 4} {
+		if ops[i] == nil || ops[i].Name != "drop" {
+			t.Fatalf("node %s must have a drop operation", testNodes[i])
+		}
+		if ops[i].RunTime != ops[0].RunTime {
+			t.Fatal("all the nodes must heal at the same time")
+		}
+	}
+}
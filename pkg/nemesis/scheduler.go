@@ -0,0 +1,328 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/tidb"
+)
+
+// Scheduler nemesis actions.
+const (
+	schedulerTransferLeader = "transfer_leader"
+	schedulerSplit          = "split"
+	schedulerMerge          = "merge"
+	schedulerScatter        = "scatter"
+	// schedulerStorm does all the actions randomly.
+	schedulerStorm = "storm"
+)
+
+var schedulerActions = []string{schedulerTransferLeader, schedulerSplit, schedulerMerge, schedulerScatter}
+
+type schedulerOptions struct {
+	action string
+	// interval is how often to add an operator.
+	interval time.Duration
+	// tidbNodes are the nodes to query the table regions, the first one answering is used.
+	tidbNodes []string
+	db        string
+	table     string
+}
+
+// parseSchedulerArgs parses arguments like action=transfer_leader|split|merge|scatter|storm,
+// interval=500ms, tidb=n1, db=test and table=accounts. The tidb argument can be repeated.
+func parseSchedulerArgs(args []string) (schedulerOptions, error) {
+	opts := schedulerOptions{
+		action:   schedulerStorm,
+		interval: 500 * time.Millisecond,
+		db:       "test",
+		table:    "accounts",
+	}
+
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return opts, err
+		}
+
+		switch key {
+		case "action":
+			opts.action = value
+		case "interval":
+			opts.interval, err = time.ParseDuration(value)
+		case "tidb":
+			opts.tidbNodes = append(opts.tidbNodes, value)
+		case "db":
+			opts.db = value
+		case "table":
+			opts.table = value
+		default:
+			err = fmt.Errorf("unknown scheduler argument %s", key)
+		}
+
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	if opts.action != schedulerStorm && indexOf(schedulerActions, opts.action) < 0 {
+		return opts, fmt.Errorf("unknown scheduler action %s", opts.action)
+	}
+
+	if opts.interval <= 0 {
+		return opts, fmt.Errorf("invalid scheduler interval %s", opts.interval)
+	}
+
+	if len(opts.tidbNodes) == 0 {
+		return opts, fmt.Errorf("no tidb to query the regions of %s.%s", opts.db, opts.table)
+	}
+
+	return opts, nil
+}
+
+// schedulerOperator builds the PD operator of the action on the table regions.
+func schedulerOperator(action string, regions []tidb.TableRegion) (map[string]interface{}, error) {
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no region to schedule")
+	}
+
+	if action == schedulerStorm {
+		action = schedulerActions[rand.Intn(len(schedulerActions))]
+	}
+
+	region := regions[rand.Intn(len(regions))]
+	switch action {
+	case schedulerTransferLeader:
+		var stores []uint64
+		for _, peer := range region.Peers {
+			if peer.StoreID != region.Leader.StoreID {
+				stores = append(stores, peer.StoreID)
+			}
+		}
+		if len(stores) == 0 {
+			return nil, fmt.Errorf("region %d has no follower", region.ID)
+		}
+		return map[string]interface{}{
+			"name":        "transfer-leader",
+			"region_id":   region.ID,
+			"to_store_id": stores[rand.Intn(len(stores))],
+		}, nil
+	case schedulerSplit:
+		return map[string]interface{}{
+			"name":      "split-region",
+			"region_id": region.ID,
+			"policy":    "approximate",
+		}, nil
+	case schedulerMerge:
+		// PD only merges the adjacent regions, the regions are in the key order.
+		if len(regions) < 2 {
+			return nil, fmt.Errorf("no region to merge")
+		}
+		i := rand.Intn(len(regions) - 1)
+		return map[string]interface{}{
+			"name":             "merge-region",
+			"source_region_id": regions[i].ID,
+			"target_region_id": regions[i+1].ID,
+		}, nil
+	case schedulerScatter:
+		return map[string]interface{}{
+			"name":      "scatter-region",
+			"region_id": region.ID,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler action %s", action)
+	}
+}
+
+// scheduler keeps asking PD to transfer the leaders, split, merge or scatter
+// the regions of the test table in the background until recovering.
+// It can run on any node, and the failed operators are only logged,
+// because PD rejects the operators on the busy regions anyway.
+type scheduler struct {
+	sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	cluster *tidb.Cluster
+}
+
+func (n *scheduler) regions(ctx context.Context, opts schedulerOptions) ([]tidb.TableRegion, error) {
+	var err error
+	for _, node := range opts.tidbNodes {
+		var regions []tidb.TableRegion
+		if regions, err = n.cluster.TableRegions(ctx, node, opts.db, opts.table); err == nil {
+			return regions, nil
+		}
+	}
+	return nil, err
+}
+
+func (n *scheduler) schedule(ctx context.Context, opts schedulerOptions) {
+	regions, err := n.regions(ctx, opts)
+	if err != nil {
+		log.Printf("get the regions of %s.%s failed %v", opts.db, opts.table, err)
+		return
+	}
+
+	op, err := schedulerOperator(opts.action, regions)
+	if err != nil {
+		log.Printf("build %s operator failed %v", opts.action, err)
+		return
+	}
+
+	if err = n.cluster.AddOperator(ctx, op); err != nil {
+		log.Printf("add operator %v failed %v", op, err)
+	}
+}
+
+func (n *scheduler) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseSchedulerArgs(args)
+	if err != nil {
+		return err
+	}
+
+	n.stop()
+
+	n.Lock()
+	defer n.Unlock()
+
+	var bgCtx context.Context
+	bgCtx, n.cancel = context.WithCancel(context.Background())
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ticker := time.NewTicker(opts.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bgCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			n.schedule(bgCtx, opts)
+		}
+	}()
+
+	return nil
+}
+
+func (n *scheduler) stop() {
+	n.Lock()
+	cancel := n.cancel
+	n.cancel = nil
+	n.Unlock()
+
+	if cancel != nil {
+		cancel()
+		n.wg.Wait()
+	}
+}
+
+func (n *scheduler) Recover(ctx context.Context, args ...string) error {
+	n.stop()
+	return nil
+}
+
+func (*scheduler) Name() string {
+	return "scheduler"
+}
+
+type schedulerGenerator struct {
+	name    string
+	cluster *tidb.Cluster
+}
+
+// Generate runs the scheduler nemesis on a PD member in the nodes, or on a random
+// node if there is none, the agent asks PD through the PD address anyway.
+func (g schedulerGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	if len(nodes) == 0 {
+		return ops
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var candidates []string
+	if pdNodes, err := g.cluster.PDNodes(ctx); err == nil {
+		for _, node := range nodes {
+			if indexOf(pdNodes, node) >= 0 {
+				candidates = append(candidates, node)
+			}
+		}
+	} else {
+		log.Printf("find the PD nodes for %s failed %v", g.name, err)
+	}
+	if len(candidates) == 0 {
+		candidates = nodes
+	}
+	target := candidates[rand.Intn(len(candidates))]
+
+	tidbNodes := g.cluster.TiDBNodes(ctx, nodes)
+	if len(tidbNodes) == 0 {
+		// The nemesis nodes may not contain the TiDB nodes, let the agent try all of them.
+		for _, node := range nodes {
+			if node != tidb.PDNode {
+				tidbNodes = append(tidbNodes, node)
+			}
+		}
+	}
+
+	action := schedulerStorm
+	interval := time.Millisecond * time.Duration(rand.Intn(900)+100)
+	switch g.name {
+	case "pd_transfer_leader":
+		action = schedulerTransferLeader
+	case "pd_split":
+		action = schedulerSplit
+	case "pd_merge":
+		action = schedulerMerge
+	case "pd_scatter":
+		action = schedulerScatter
+	default:
+		interval = time.Millisecond * time.Duration(rand.Intn(90)+10)
+	}
+
+	args := []string{
+		fmt.Sprintf("action=%s", action),
+		fmt.Sprintf("interval=%s", interval),
+	}
+	for _, node := range tidbNodes {
+		args = append(args, fmt.Sprintf("tidb=%s", node))
+	}
+
+	ops[indexOf(nodes, target)] = &core.NemesisOperation{
+		Name:       "scheduler",
+		InvokeArgs: args,
+		RunTime:    time.Second * time.Duration(rand.Intn(10)+1),
+	}
+	return ops
+}
+
+func (g schedulerGenerator) Name() string {
+	return g.name
+}
+
+// NewSchedulerGenerator creates a generator which asks PD to schedule the regions
+// of the test table. Name is pd_transfer_leader, pd_split, pd_merge, pd_scatter
+// and pd_scheduler_storm, the storm does all the actions at a higher rate.
+func NewSchedulerGenerator(name string) core.NemesisGenerator {
+	return schedulerGenerator{name: name, cluster: tidb.NewCluster(tidb.PDAddr)}
+}
+
+func init() {
+	core.RegisterNemesis(&scheduler{cluster: tidb.NewCluster(tidb.PDAddr)})
+}
@@ -0,0 +1,66 @@
+package nemesis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/chaos/tidb"
+)
+
+func TestSchedulerOperator(t *testing.T) {
+	opts, err := parseSchedulerArgs([]string{"action=transfer_leader", "interval=10ms", "tidb=n1", "tidb=n2"})
+	if err != nil {
+		t.Fatalf("parse scheduler args failed %v", err)
+	}
+
+	if opts.action != schedulerTransferLeader || len(opts.tidbNodes) != 2 || opts.table != "accounts" {
+		t.Fatalf("invalid scheduler options %+v", opts)
+	}
+
+	if _, err = parseSchedulerArgs([]string{"action=move"}); err == nil {
+		t.Fatal("unknown action must fail")
+	}
+
+	regions := []tidb.TableRegion{{
+		ID:     2,
+		Leader: tidb.TablePeer{ID: 3, StoreID: 1},
+		Peers:  []tidb.TablePeer{{ID: 3, StoreID: 1}, {ID: 4, StoreID: 5}},
+	}}
+
+	op, err := schedulerOperator(schedulerTransferLeader, regions)
+	if err != nil || op["name"] != "transfer-leader" || op["to_store_id"] != uint64(5) {
+		t.Fatalf("invalid transfer leader operator %v %v", op, err)
+	}
+
+	if _, err = schedulerOperator(schedulerMerge, regions); err == nil {
+		t.Fatal("merge one region must fail")
+	}
+
+	regions = append(regions, tidb.TableRegion{ID: 6})
+	op, err = schedulerOperator(schedulerMerge, regions)
+	if err != nil || op["source_region_id"] != uint64(2) || op["target_region_id"] != uint64(6) {
+		t.Fatalf("invalid merge operator %v %v", op, err)
+	}
+
+}
+
+func TestSchedulerGenerator(t *testing.T) {
+	pd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"members": [{"name": "pd"}, {"name": "n2"}]}`))
+	}))
+	defer pd.Close()
+
+	g := schedulerGenerator{name: "pd_split", cluster: tidb.NewCluster(strings.TrimPrefix(pd.URL, "http://"))}
+	ops := g.Generate([]string{"n1", "n2", "n3"})
+	if ops[0] != nil || ops[1] == nil || ops[2] != nil {
+		t.Fatalf("must run on the PD member n2, but got %v", ops)
+	}
+
+	// Without the PD member, any node can ask PD.
+	ops = g.Generate([]string{"n1", "n3"})
+	if ops[0] == nil && ops[1] == nil {
+		t.Fatal("must run on a node without the PD member")
+	}
+}
@@ -0,0 +1,238 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/tidb"
+)
+
+// Selector selects the nodes to disturb from the nodes, it is resolved
+// against the live cluster state each time.
+type Selector interface {
+	Select(ctx context.Context, nodes []string) ([]string, error)
+	String() string
+}
+
+// Selector roles.
+const (
+	roleAll              = "all"
+	rolePD               = "pd"
+	rolePDLeader         = "pd_leader"
+	roleTiKV             = "tikv"
+	roleTiKVRegionLeader = "tikv_region_leader"
+	roleTiDB             = "tidb"
+)
+
+var selectorRoles = []string{roleAll, rolePD, rolePDLeader, roleTiKV, roleTiKVRegionLeader, roleTiDB}
+
+// roleSelector selects the nodes which play the role in the live cluster.
+type roleSelector struct {
+	role string
+	// regionID is the region for tikv_region_leader, zero means a random region.
+	regionID uint64
+	cluster  *tidb.Cluster
+}
+
+func (s roleSelector) Select(ctx context.Context, nodes []string) ([]string, error) {
+	var (
+		roleNodes []string
+		err       error
+	)
+
+	switch s.role {
+	case roleAll:
+		return nodes, nil
+	case rolePD:
+		roleNodes, err = s.cluster.PDNodes(ctx)
+	case rolePDLeader:
+		var leader string
+		leader, err = s.cluster.PDLeader(ctx)
+		roleNodes = []string{leader}
+	case roleTiKV:
+		roleNodes, err = s.cluster.TiKVNodes(ctx)
+	case roleTiKVRegionLeader:
+		var leader string
+		leader, err = s.cluster.RegionLeaderNode(ctx, s.regionID)
+		roleNodes = []string{leader}
+	case roleTiDB:
+		roleNodes = s.cluster.TiDBNodes(ctx, nodes)
+	default:
+		err = fmt.Errorf("unknown role %s", s.role)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the nodes we can disturb are selected.
+	var selected []string
+	for _, node := range nodes {
+		if indexOf(roleNodes, node) >= 0 {
+			selected = append(selected, node)
+		}
+	}
+	return selected, nil
+}
+
+func (s roleSelector) String() string {
+	return s.role
+}
+
+// notSelector selects the nodes which the selector doesn't select.
+type notSelector struct {
+	s Selector
+}
+
+func (s notSelector) Select(ctx context.Context, nodes []string) ([]string, error) {
+	excluded, err := s.s.Select(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, node := range nodes {
+		if indexOf(excluded, node) < 0 {
+			selected = append(selected, node)
+		}
+	}
+	return selected, nil
+}
+
+func (s notSelector) String() string {
+	return "not_" + s.s.String()
+}
+
+// nodesSelector selects exactly the nodes.
+type nodesSelector []string
+
+func (s nodesSelector) Select(ctx context.Context, nodes []string) ([]string, error) {
+	var selected []string
+	for _, node := range nodes {
+		if indexOf(s, node) >= 0 {
+			selected = append(selected, node)
+		}
+	}
+	return selected, nil
+}
+
+func (s nodesSelector) String() string {
+	return strings.Join(s, ",")
+}
+
+// NewRoleSelector creates a selector which selects the nodes playing the role,
+// role is all, pd, pd_leader, tikv, tikv_region_leader and tidb.
+func NewRoleSelector(role string) Selector {
+	return roleSelector{role: role, cluster: tidb.NewCluster(tidb.PDAddr)}
+}
+
+// NewRegionLeaderSelector creates a selector which selects the TiKV
+// holding the leader of the region, zero region ID means a random region.
+func NewRegionLeaderSelector(regionID uint64) Selector {
+	return roleSelector{role: roleTiKVRegionLeader, regionID: regionID, cluster: tidb.NewCluster(tidb.PDAddr)}
+}
+
+// ParseSelector parses the selector, like tikv, pd_leader, not_tidb,
+// or the exact nodes like n2,n4.
+func ParseSelector(spec string) (Selector, error) {
+	spec = strings.TrimSpace(spec)
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	if strings.HasPrefix(spec, "not_") {
+		s, err := ParseSelector(strings.TrimPrefix(spec, "not_"))
+		if err != nil {
+			return nil, err
+		}
+		return notSelector{s: s}, nil
+	}
+
+	if indexOf(selectorRoles, spec) >= 0 {
+		return NewRoleSelector(spec), nil
+	}
+
+	var nodes nodesSelector
+	for _, node := range strings.Split(spec, ",") {
+		if node = strings.TrimSpace(node); len(node) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("invalid selector %s", spec)
+	}
+	return nodes, nil
+}
+
+// targetGenerator runs the generator on the selected nodes only.
+type targetGenerator struct {
+	s   Selector
+	gen core.NemesisGenerator
+}
+
+// selectIndices returns the selected nodes and their indices in the nodes.
+func (g targetGenerator) selectIndices(nodes []string) ([]string, []int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	selected, err := g.s.Select(ctx, nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indices := make([]int, len(selected))
+	for i, node := range selected {
+		indices[i] = indexOf(nodes, node)
+	}
+	return selected, indices, nil
+}
+
+// expand maps the operations for the selected nodes back to the nodes.
+func expand(ops []*core.NemesisOperation, indices []int, n int) []*core.NemesisOperation {
+	all := make([]*core.NemesisOperation, n)
+	for i, op := range ops {
+		if i < len(indices) && indices[i] >= 0 {
+			all[indices[i]] = op
+		}
+	}
+	return all
+}
+
+func (g targetGenerator) GenerateGroups(nodes []string) [][]*core.NemesisOperation {
+	selected, indices, err := g.selectIndices(nodes)
+	if err != nil {
+		log.Printf("select %s from %v for %s failed %v", g.s, nodes, g.gen.Name(), err)
+	} else if len(selected) == 0 {
+		log.Printf("no node in %v is %s for %s", nodes, g.s, g.gen.Name())
+	}
+
+	// Run the generator even if nothing is selected, so the empty round counts
+	// toward the limit and the exhausted generator stops the dispatching.
+	groups := generateGroups(g.gen, selected)
+	if groups == nil {
+		return nil
+	}
+
+	for i, ops := range groups {
+		groups[i] = expand(ops, indices, len(nodes))
+	}
+	return groups
+}
+
+func (g targetGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	return mergeGroups(g.GenerateGroups(nodes), len(nodes))
+}
+
+func (g targetGenerator) Name() string {
+	return fmt.Sprintf("%s@%s", g.gen.Name(), g.s)
+}
+
+// NewTargetGenerator creates a generator which runs the generator on the nodes
+// selected by the selector, e.g. killing TiKV on the nodes not running TiDB.
+func NewTargetGenerator(s Selector, gen core.NemesisGenerator) core.NemesisGenerator {
+	return targetGenerator{s: s, gen: gen}
+}
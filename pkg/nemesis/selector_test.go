@@ -0,0 +1,66 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/chaos/tidb"
+)
+
+func TestSelector(t *testing.T) {
+	nodes := []string{"pd", "n1", "n2", "n3", "n4"}
+	ctx := context.Background()
+
+	s, err := ParseSelector("n4, n2,n5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected, _ := s.Select(ctx, nodes); fmt.Sprint(selected) != "[n2 n4]" {
+		t.Fatalf("must select n2 and n4, but got %v", selected)
+	}
+
+	s, err = ParseSelector("not_n1,n3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected, _ := s.Select(ctx, nodes); fmt.Sprint(selected) != "[pd n2 n4]" {
+		t.Fatalf("must select pd, n2 and n4, but got %v", selected)
+	}
+
+	pd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"members": [{"name": "pd"}, {"name": "n3"}, {"name": "n9"}]}`))
+	}))
+	defer pd.Close()
+
+	s = notSelector{s: roleSelector{role: rolePD, cluster: tidb.NewCluster(strings.TrimPrefix(pd.URL, "http://"))}}
+	if selected, _ := s.Select(ctx, nodes); fmt.Sprint(selected) != "[n1 n2 n4]" {
+		t.Fatalf("must select the nodes without pd, but got %v", selected)
+	}
+
+	g := NewTargetGenerator(nodesSelector{"n3"}, nodeGenerator{name: "a", node: 0})
+	ops := g.Generate(nodes)
+	for i, op := range ops {
+		if (i == 3) != (op != nil) {
+			t.Fatalf("must only run a on n3, but got %v", ops)
+		}
+	}
+
+	// The rounds without any selected node still count toward the limit.
+	g = NewTargetGenerator(nodesSelector{"n9"}, NewLimitGenerator(2, nodeGenerator{name: "a", node: 0}))
+	for i := 0; i < 2; i++ {
+		if ops = g.Generate(nodes); ops == nil || len(ops) != len(nodes) || ops[0] != nil {
+			t.Fatalf("must run nothing, but got %v", ops)
+		}
+	}
+	if ops = g.Generate(nodes); ops != nil {
+		t.Fatalf("the limit must be exhausted, but got %v", ops)
+	}
+
+	if _, err = ParseSelector(" , "); err == nil {
+		t.Fatal("empty selector must fail")
+	}
+}
@@ -0,0 +1,118 @@
+package nemesis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util/net"
+	"github.com/siddontang/chaos/tidb"
+)
+
+// parseShapeArgs parses the netem arguments and the targets given by
+// dst=host, dst=host:port or dst=:port.
+func parseShapeArgs(args []string) (net.NetemOptions, []net.Target, error) {
+	var (
+		netemArgs []string
+		targets   []net.Target
+	)
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "dst=") {
+			netemArgs = append(netemArgs, arg)
+			continue
+		}
+
+		t, err := net.ParseTarget(strings.TrimPrefix(arg, "dst="))
+		if err != nil {
+			return net.NetemOptions{}, nil, err
+		}
+		targets = append(targets, t)
+	}
+
+	opts, err := parseNetemArgs("shape", netemArgs)
+	return opts, targets, err
+}
+
+// shape shapes the traffic from the node to the specified targets only,
+// so we can slow down a link between two nodes in one direction.
+type shape struct {
+	t net.TC
+}
+
+func (n shape) Invoke(ctx context.Context, args ...string) error {
+	opts, targets, err := parseShapeArgs(args)
+	if err != nil {
+		return err
+	}
+
+	return n.t.Shape(ctx, opts, targets...)
+}
+
+func (n shape) Recover(ctx context.Context, args ...string) error {
+	return n.t.Fast(ctx)
+}
+
+func (shape) Name() string {
+	return "shape"
+}
+
+type shapeGenerator struct {
+	name string
+}
+
+func (g shapeGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	runTime := time.Second * time.Duration(rand.Intn(10)+1)
+	delay := fmt.Sprintf("delay=%dms", rand.Intn(450)+50)
+
+	switch g.name {
+	case "slow_pd", "slow_tikv":
+		var dsts []string
+		if g.name == "slow_pd" {
+			dsts = []string{fmt.Sprintf("dst=:%d", tidb.PDClientPort), fmt.Sprintf("dst=:%d", tidb.PDPeerPort)}
+		} else {
+			dsts = []string{fmt.Sprintf("dst=:%d", tidb.TiKVPort)}
+		}
+
+		for i := range nodes {
+			ops[i] = &core.NemesisOperation{
+				Name:       "shape",
+				InvokeArgs: append([]string{delay}, dsts...),
+				RunTime:    runTime,
+			}
+		}
+	default:
+		// slow_link slows down the traffic from one node to another one only.
+		if len(nodes) < 2 {
+			return ops
+		}
+
+		indices := shuffleIndices(len(nodes))
+		from, to := indices[0], indices[1]
+		ops[from] = &core.NemesisOperation{
+			Name:       "shape",
+			InvokeArgs: []string{delay, fmt.Sprintf("dst=%s", nodes[to])},
+			RunTime:    runTime,
+		}
+	}
+
+	return ops
+}
+
+func (g shapeGenerator) Name() string {
+	return g.name
+}
+
+// NewShapeGenerator creates a generator which slows down the traffic to some destinations.
+// Name is slow_link, slow_pd and slow_tikv.
+func NewShapeGenerator(name string) core.NemesisGenerator {
+	return shapeGenerator{name: name}
+}
+
+func init() {
+	core.RegisterNemesis(shape{})
+}
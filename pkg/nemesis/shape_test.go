@@ -0,0 +1,31 @@
+package nemesis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseShapeArgs(t *testing.T) {
+	opts, targets, err := parseShapeArgs([]string{"delay=100ms", "dst=n2", "dst=:20160", "dst=n3:2379"})
+	if err != nil {
+		t.Fatalf("parse shape args failed %v", err)
+	}
+
+	if opts.Delay != 100*time.Millisecond {
+		t.Fatalf("invalid shape options %+v", opts)
+	}
+
+	if len(targets) != 3 {
+		t.Fatalf("must have 3 targets, but got %v", targets)
+	}
+
+	if targets[0].Host != "n2" || targets[0].Port != 0 ||
+		targets[1].Host != "" || targets[1].Port != 20160 ||
+		targets[2].Host != "n3" || targets[2].Port != 2379 {
+		t.Fatalf("invalid targets %v", targets)
+	}
+
+	if _, _, err = parseShapeArgs([]string{"delay=100ms", "dst=n2:pd"}); err == nil {
+		t.Fatal("invalid target port must fail")
+	}
+}
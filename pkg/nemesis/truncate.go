@@ -0,0 +1,381 @@
+package nemesis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util/disk"
+	"github.com/siddontang/chaos/tidb"
+)
+
+type truncateOptions struct {
+	db      string
+	service string
+	// files are the glob patterns of the files to damage.
+	files []string
+	// size is how many bytes of the tail to damage.
+	size int64
+	// corrupt overwrites the tail with random data instead of cutting it off.
+	corrupt bool
+	// all damages all the matched files instead of the latest one.
+	all bool
+}
+
+// truncateArgs are the structured arguments of the truncate nemesis.
+type truncateArgs struct {
+	DB      string `json:"db"`
+	Service string `json:"service"`
+	// Files are the glob patterns, which may contain braces like {raft,db}/*.log,
+	// a pattern with braces is the same as the patterns it expands to.
+	Files []string `json:"files"`
+	Size  int64    `json:"size,omitempty"`
+	// Mode is truncate or corrupt, default truncate.
+	Mode string `json:"mode,omitempty"`
+	// Target is latest or all, default latest.
+	Target string `json:"target,omitempty"`
+}
+
+func (a *truncateArgs) options() (truncateOptions, error) {
+	opts := truncateOptions{
+		db:      a.DB,
+		service: a.Service,
+		files:   a.Files,
+		size:    a.Size,
+	}
+	if opts.size == 0 {
+		opts.size = 4096
+	}
+
+	switch a.Mode {
+	case "", "truncate":
+	case "corrupt":
+		opts.corrupt = true
+	default:
+		return opts, fmt.Errorf("unknown truncate mode %s", a.Mode)
+	}
+
+	switch a.Target {
+	case "", "latest":
+	case "all":
+		opts.all = true
+	default:
+		return opts, fmt.Errorf("unknown truncate target %s", a.Target)
+	}
+
+	if core.GetDB(opts.db) == nil {
+		return opts, fmt.Errorf("db %s is not registered", opts.db)
+	}
+
+	if len(opts.files) == 0 {
+		return opts, fmt.Errorf("no file to truncate")
+	}
+
+	return opts, nil
+}
+
+// parseTruncateArgs parses arguments like db=tidb, service=tikv,
+// file=/opt/tidb/tikv/raft/*.log, size=4096, mode=truncate|corrupt
+// and target=latest|all. The file argument can be repeated.
+func parseTruncateArgs(args []string) (truncateOptions, error) {
+	var a truncateArgs
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		key, value, err := splitArg(arg)
+		if err != nil {
+			return truncateOptions{}, err
+		}
+
+		switch key {
+		case "db":
+			a.DB = value
+		case "service":
+			a.Service = value
+		case "file":
+			a.Files = append(a.Files, value)
+		case "size":
+			a.Size, err = strconv.ParseInt(value, 10, 64)
+		case "mode":
+			a.Mode = value
+		case "target":
+			a.Target = value
+		default:
+			err = fmt.Errorf("unknown truncate argument %s", key)
+		}
+
+		if err != nil {
+			return truncateOptions{}, err
+		}
+	}
+
+	return a.options()
+}
+
+// truncate kills the service and then damages the tail of its log files,
+// which simulates losing the writes not fsynced yet when the node crashes.
+// The killed service is restarted when recovering.
+type truncate struct{}
+
+func (n truncate) Invoke(ctx context.Context, args ...string) error {
+	opts, err := parseTruncateArgs(args)
+	if err != nil {
+		return err
+	}
+	return n.invoke(ctx, opts)
+}
+
+func (n truncate) invoke(ctx context.Context, opts truncateOptions) error {
+	db := core.GetDB(opts.db)
+	// Killing a service not running here is a no-op, and recovering would
+	// start the service which the node never had.
+	if !db.IsRunning(ctx, opts.service) {
+		return fmt.Errorf("service %s is not running", opts.service)
+	}
+	if err := db.Kill(ctx, opts.service); err != nil {
+		return err
+	}
+
+	var err error
+	for _, pattern := range expandPatterns(opts.files) {
+		var names []string
+		if opts.all {
+			if names, err = filepath.Glob(pattern); err != nil {
+				return err
+			}
+		} else {
+			name, err := disk.LatestFile(pattern)
+			if err != nil {
+				// The service may not write this kind of file yet.
+				log.Printf("skip truncating %s: %v", pattern, err)
+				continue
+			}
+			names = []string{name}
+		}
+
+		for _, name := range names {
+			log.Printf("damage the last %d bytes of %s, corrupt %v", opts.size, name, opts.corrupt)
+			if opts.corrupt {
+				err = disk.CorruptTail(name, opts.size)
+			} else {
+				err = disk.TruncateTail(name, opts.size)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (n truncate) Recover(ctx context.Context, args ...string) error {
+	opts, err := parseTruncateArgs(args)
+	if err != nil {
+		return err
+	}
+	return n.recover(ctx, opts)
+}
+
+// recover restarts the service only if it was killed, it checks the state
+// instead of remembering the kill, so it works for the journaled nemesis
+// recovered by a restarted agent too.
+func (truncate) recover(ctx context.Context, opts truncateOptions) error {
+	db := core.GetDB(opts.db)
+	if db.IsRunning(ctx, opts.service) {
+		return nil
+	}
+
+	for _, pattern := range expandPatterns(opts.files) {
+		if names, err := filepath.Glob(pattern); err == nil && len(names) > 0 {
+			return startService(ctx, db, opts.service)
+		}
+	}
+
+	log.Printf("service %s has no log file, skip starting it", opts.service)
+	return nil
+}
+
+// expandPatterns expands the braces in the glob patterns, which filepath.Glob
+// doesn't support, like the shell, a/{b,c}/d becomes a/b/d and a/c/d.
+func expandPatterns(patterns []string) []string {
+	var expanded []string
+	for _, pattern := range patterns {
+		expanded = append(expanded, expandBraces(pattern)...)
+	}
+	return expanded
+}
+
+func expandBraces(pattern string) []string {
+	start := strings.Index(pattern, "{")
+	if start < 0 {
+		return []string{pattern}
+	}
+
+	// Find the matched close brace and split the alternatives at the top level commas.
+	var (
+		alternatives []string
+		depth        int
+		last         = start + 1
+	)
+	for i := start + 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+				continue
+			}
+
+			alternatives = append(alternatives, pattern[last:i])
+			var expanded []string
+			for _, alt := range alternatives {
+				expanded = append(expanded, expandBraces(pattern[:start]+alt+pattern[i+1:])...)
+			}
+			return expanded
+		}
+	}
+
+	// The brace is not closed, take it literally.
+	return []string{pattern}
+}
+
+func (truncate) NewArgs() interface{} {
+	return new(truncateArgs)
+}
+
+func (n truncate) InvokeWith(ctx context.Context, args interface{}) error {
+	opts, err := args.(*truncateArgs).options()
+	if err != nil {
+		return err
+	}
+	return n.invoke(ctx, opts)
+}
+
+func (n truncate) RecoverWith(ctx context.Context, args interface{}) error {
+	opts, err := args.(*truncateArgs).options()
+	if err != nil {
+		return err
+	}
+	return n.recover(ctx, opts)
+}
+
+func (truncate) Name() string {
+	return "truncate"
+}
+
+// walFiles returns the glob patterns of the write ahead log and the raft log files of the service.
+func walFiles(service string) []string {
+	switch service {
+	case tidb.SERVICE_PD:
+		return []string{path.Join(tidb.PDDataDir, "member/wal/*.wal")}
+	default:
+		return []string{
+			path.Join(tidb.TiKVDataDir, "raft/*.log"),
+			path.Join(tidb.TiKVDataDir, "db/*.log"),
+		}
+	}
+}
+
+type truncateGenerator struct {
+	db      string
+	name    string
+	cluster *tidb.Cluster
+}
+
+// Generate damages the logs of the service on a random node running it.
+func (g truncateGenerator) Generate(nodes []string) []*core.NemesisOperation {
+	ops := make([]*core.NemesisOperation, len(nodes))
+	if len(nodes) == 0 {
+		return ops
+	}
+
+	service, mode := tidb.SERVICE_TIKV, "truncate"
+	switch g.name {
+	case "corrupt_tikv_wal":
+		mode = "corrupt"
+	case "truncate_pd_wal":
+		service = tidb.SERVICE_PD
+	case "corrupt_pd_wal":
+		service, mode = tidb.SERVICE_PD, "corrupt"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		serviceNodes []string
+		err          error
+	)
+	if service == tidb.SERVICE_PD {
+		serviceNodes, err = g.cluster.PDNodes(ctx)
+	} else {
+		serviceNodes, err = g.cluster.TiKVNodes(ctx)
+	}
+	if err != nil {
+		log.Printf("find the nodes for %s failed %v", g.name, err)
+		return ops
+	}
+
+	var candidates []string
+	for _, node := range nodes {
+		if indexOf(serviceNodes, node) >= 0 {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		log.Printf("no node in %v for %s", nodes, g.name)
+		return ops
+	}
+
+	args, err := json.Marshal(truncateArgs{
+		DB:      g.db,
+		Service: service,
+		Files:   walFiles(service),
+		Size:    int64(rand.Intn(64*1024) + 1),
+		Mode:    mode,
+	})
+	if err != nil {
+		log.Printf("encode the arguments for %s failed %v", g.name, err)
+		return ops
+	}
+
+	target := candidates[rand.Intn(len(candidates))]
+	ops[indexOf(nodes, target)] = &core.NemesisOperation{
+		Name:    "truncate",
+		Args:    args,
+		RunTime: time.Second * time.Duration(rand.Intn(10)+1),
+	}
+	return ops
+}
+
+func (g truncateGenerator) Name() string {
+	return g.name
+}
+
+// NewTruncateGenerator creates a generator which damages the tail of the log files.
+// Name is truncate_tikv_wal, corrupt_tikv_wal, truncate_pd_wal and corrupt_pd_wal.
+func NewTruncateGenerator(db string, name string) core.NemesisGenerator {
+	return truncateGenerator{db: db, name: name, cluster: tidb.NewCluster(tidb.PDAddr)}
+}
+
+func init() {
+	core.RegisterNemesis(truncate{})
+}
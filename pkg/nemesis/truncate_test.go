@@ -0,0 +1,99 @@
+package nemesis
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/tidb"
+)
+
+func TestParseTruncateArgs(t *testing.T) {
+	pd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"members": [{"name": "pd"}, {"name": "n2"}]}`))
+	}))
+	defer pd.Close()
+
+	g := truncateGenerator{db: "noop", name: "corrupt_pd_wal", cluster: tidb.NewCluster(strings.TrimPrefix(pd.URL, "http://"))}
+	ops := g.Generate([]string{"n1", "n2"})
+	if ops[0] != nil || ops[1] == nil {
+		t.Fatalf("must only damage the PD on n2, but got %v", ops)
+	}
+	op := ops[1]
+
+	n := core.GetNemesis("truncate").(core.TypedNemesis)
+	args := n.NewArgs()
+	if err := json.Unmarshal(op.Args, args); err != nil {
+		t.Fatalf("decode truncate args failed %v", err)
+	}
+
+	opts, err := args.(*truncateArgs).options()
+	if err != nil {
+		t.Fatalf("parse truncate args failed %v", err)
+	}
+
+	if opts.service != "pd" || !opts.corrupt || opts.all || opts.size <= 0 {
+		t.Fatalf("invalid truncate options %+v", opts)
+	}
+
+	if len(opts.files) != 1 || opts.files[0] != "/opt/tidb/pd/member/wal/*.wal" {
+		t.Fatalf("invalid truncate files %v", opts.files)
+	}
+
+	opts, err = parseTruncateArgs([]string{"db=noop", "service=tikv", "file=/tmp/{raft,db}/*.log", "target=all"})
+	if err != nil || !opts.all || opts.size != 4096 || opts.files[0] != "/tmp/{raft,db}/*.log" {
+		t.Fatalf("invalid truncate options %+v %v", opts, err)
+	}
+
+	if _, err = parseTruncateArgs([]string{"db=noop", "service=tikv"}); err == nil {
+		t.Fatal("no file must fail")
+	}
+
+	if _, err = parseTruncateArgs([]string{"db=unknown", "file=/tmp/*.log"}); err == nil {
+		t.Fatal("unregistered db must fail")
+	}
+
+	if err = core.InvokeNemesis(context.Background(), core.NoopNemesis{}, nil, op.Args); err == nil {
+		t.Fatal("structured args for the untyped nemesis must fail")
+	}
+}
+
+func TestTruncateFiles(t *testing.T) {
+	got := expandPatterns([]string{"/a/{raft,db/{1,2}}/*.log", "/b/*.wal", "/c/{x"})
+	want := []string{"/a/raft/*.log", "/a/db/1/*.log", "/a/db/2/*.log", "/b/*.wal", "/c/{x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("patterns must be expanded to %v, but got %v", want, got)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "truncate")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, dir := range []string{"raft", "db"} {
+		os.MkdirAll(path.Join(tmpDir, dir), 0755)
+		if err = ioutil.WriteFile(path.Join(tmpDir, dir, "1.log"), []byte("hello world"), 0644); err != nil {
+			t.Fatalf("write file failed %v", err)
+		}
+	}
+
+	pattern := path.Join(tmpDir, "{raft,db}/*.log")
+	if err = core.GetNemesis("truncate").Invoke(context.Background(), "db=noop", "service=tikv", "file="+pattern, "size=6", "target=all"); err != nil {
+		t.Fatalf("truncate failed %v", err)
+	}
+
+	for _, dir := range []string{"raft", "db"} {
+		if data, _ := ioutil.ReadFile(path.Join(tmpDir, dir, "1.log")); string(data) != "hello" {
+			t.Fatalf("the log in %s must be truncated to hello, but got %q", dir, data)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package node
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/siddontang/chaos/pkg/core"
 	"github.com/unrolled/render"
 	"github.com/urfave/negroni"
 )
@@ -20,22 +22,136 @@ type Agent struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	dbLock      sync.Mutex
-	nemesisLock sync.Mutex
+	dbLock sync.Mutex
+
+	// nemesisLocks serialize the operations of the same nemesis,
+	// the different nemeses can run on the node concurrently.
+	nemesisLocksMu sync.Mutex
+	nemesisLocks   map[string]*sync.Mutex
+
+	journalPath string
+	journal     *journal
+
+	// running are the nemeses waiting to recover, heal-all cancels them.
+	runningMu sync.Mutex
+	running   map[uint64]*runningNemesis
+}
+
+type runningNemesis struct {
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // NewAgent creates the agent with given address
 func NewAgent(addr string) *Agent {
 	agent := &Agent{
-		addr: addr,
+		addr:         addr,
+		nemesisLocks: make(map[string]*sync.Mutex),
+		journalPath:  DefaultJournal,
+		journal:      new(journal),
+		running:      make(map[uint64]*runningNemesis),
 	}
 
 	agent.ctx, agent.cancel = context.WithCancel(context.Background())
 	return agent
 }
 
+// nemesisLock returns the lock of the nemesis.
+func (agent *Agent) nemesisLock(name string) *sync.Mutex {
+	agent.nemesisLocksMu.Lock()
+	defer agent.nemesisLocksMu.Unlock()
+
+	l, ok := agent.nemesisLocks[name]
+	if !ok {
+		l = new(sync.Mutex)
+		agent.nemesisLocks[name] = l
+	}
+	return l
+}
+
+// SetJournal sets the journal file of the active nemeses, empty means
+// only keeping them in memory. It must be called before Run.
+func (agent *Agent) SetJournal(name string) {
+	agent.journalPath = name
+}
+
+// startNemesis tracks the running nemesis until it is recovered.
+func (agent *Agent) startNemesis(id uint64, cancel context.CancelFunc) *runningNemesis {
+	agent.runningMu.Lock()
+	defer agent.runningMu.Unlock()
+
+	n := &runningNemesis{cancel: cancel, done: make(chan struct{})}
+	agent.running[id] = n
+	return n
+}
+
+func (agent *Agent) finishNemesis(id uint64) {
+	agent.runningMu.Lock()
+	defer agent.runningMu.Unlock()
+
+	if n, ok := agent.running[id]; ok {
+		close(n.done)
+		delete(agent.running, id)
+	}
+}
+
+// healAll recovers the running nemeses at once, and then recovers the nemeses
+// left in the journal, which failed to recover or were left by the crashed agent.
+func (agent *Agent) healAll(ctx context.Context) error {
+	agent.runningMu.Lock()
+	running := make([]*runningNemesis, 0, len(agent.running))
+	for _, n := range agent.running {
+		running = append(running, n)
+	}
+	agent.runningMu.Unlock()
+
+	for _, n := range running {
+		n.cancel()
+	}
+	for _, n := range running {
+		select {
+		case <-n.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var lastErr error
+	for _, e := range agent.journal.Entries() {
+		nemesis := core.GetNemesis(e.Name)
+		if nemesis == nil {
+			log.Printf("drop the journal of unknown nemesis %s", e.Name)
+			agent.journal.Remove(e.ID)
+			continue
+		}
+
+		log.Printf("heal nemesis %s with %v %s started at %s", e.Name, e.RecoverArgs, e.Args, e.Start)
+		if err := core.RecoverNemesis(ctx, nemesis, e.RecoverArgs, e.Args); err != nil {
+			log.Printf("heal nemesis %s failed %v", e.Name, err)
+			lastErr = err
+			continue
+		}
+
+		if err := agent.journal.Remove(e.ID); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // Run runs the agent API server
 func (agent *Agent) Run() error {
+	j, err := openJournal(agent.journalPath)
+	if err != nil {
+		return err
+	}
+	agent.journal = j
+
+	// Heal the faults left by the crashed agent before serving.
+	if err = agent.healAll(agent.ctx); err != nil {
+		log.Printf("heal the leftover nemeses failed %v", err)
+	}
+
 	agent.s = &http.Server{
 		Addr:    agent.addr,
 		Handler: agent.createHandler(),
@@ -79,12 +195,18 @@ func (agent *Agent) createRouter() *mux.Router {
 	// router.HandleFunc("/nemesis/{name}/setup", nemesisHandler.SetUp).Methods("POST")
 	// router.HandleFunc("/nemesis/{name}/teardown", nemesisHandler.TearDown).Methods("POST")
 	router.HandleFunc("/nemesis/{name}/run", nemesisHandler.Run).Methods("POST")
+	router.HandleFunc("/heal-all", nemesisHandler.HealAll).Methods("POST")
+
+	statusHandler := newStatusHandler(agent, rd)
+	router.HandleFunc("/status", statusHandler.Status).Methods("GET")
 
 	dbHandler := newDBHanlder(agent, rd)
 	router.HandleFunc("/db/{name}/setup", dbHandler.SetUp).Methods("POST")
 	router.HandleFunc("/db/{name}/start", dbHandler.Start).Methods("POST")
 	router.HandleFunc("/db/{name}/teardown", dbHandler.TearDown).Methods("POST")
 	router.HandleFunc("/db/{name}/kill", dbHandler.Kill).Methods("POST")
+	router.HandleFunc("/db/{name}/ready", dbHandler.Ready).Methods("POST")
+	router.HandleFunc("/db/{name}/archive", dbHandler.Archive).Methods("GET")
 
 	return router
 }
@@ -2,10 +2,13 @@ package node
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/siddontang/chaos/pkg/core"
@@ -60,6 +63,28 @@ func (c *Client) doPost(suffix string, args url.Values, data []byte) error {
 	return nil
 }
 
+func (c *Client) doGet(suffix string, v interface{}) error {
+	args := url.Values{}
+	args.Set("node", c.node)
+	url := fmt.Sprintf("%s%s?%s", c.getURLPrefix(), suffix, args.Encode())
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s:%s", resp.Status, data)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
 // SetUpDB is to set up the db
 func (c *Client) SetUpDB(name string, nodes []string) error {
 	v := url.Values{}
@@ -82,39 +107,61 @@ func (c *Client) Start(name string, service string) error {
 	return c.doPost(fmt.Sprintf("/db/%s/start", name), v, nil)
 }
 
-
-// StopDB stops db
-func (c *Client) StopDB(name string) error {
-	return c.doPost(fmt.Sprintf("/db/%s/stop", name), nil, nil)
-}
-
 // KillDB kills db
 func (c *Client) Kill(name string, service string) error {
 	v := url.Values{}
 	v.Set("service", service)
-	return c.doPost(fmt.Sprintf("/db/%s/kill", name), nil, nil)
+	return c.doPost(fmt.Sprintf("/db/%s/kill", name), v, nil)
 }
 
-// IsDBRunning checks db is running
-func (c *Client) IsDBRunning(name string) bool {
-	return c.doPost(fmt.Sprintf("/db/%s/is_running", name), nil, nil) == nil
+// Ready checks whether the service of db is ready to serve
+func (c *Client) Ready(name string, service string) error {
+	v := url.Values{}
+	v.Set("service", service)
+	return c.doPost(fmt.Sprintf("/db/%s/ready", name), v, nil)
 }
 
 // RunNemesis runs nemesis
 func (c *Client) RunNemesis(op *core.NemesisOperation) error {
-	v := url.Values{}
-	suffix := fmt.Sprintf("/nemesis/%s/run", op.Name)
-	if op.RunTime > 0 {
-		v.Set("dur", op.RunTime.String())
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
 	}
 
-	if len(op.InvokeArgs) > 0 {
-		v.Set("invoke_args", strings.Join(op.InvokeArgs, ","))
+	return c.doPost(fmt.Sprintf("/nemesis/%s/run", op.Name), nil, data)
+}
+
+// HealAll recovers all the active nemeses on the node
+func (c *Client) HealAll() error {
+	return c.doPost("/heal-all", nil, nil)
+}
+
+// Archive writes the tar.gz archive of the db files to w, withData adds the data
+func (c *Client) Archive(name string, withData bool, w io.Writer) error {
+	args := url.Values{}
+	args.Set("node", c.node)
+	args.Set("data", strconv.FormatBool(withData))
+	url := fmt.Sprintf("%s/db/%s/archive?%s", c.getURLPrefix(), name, args.Encode())
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	if len(op.RecoverArgs) > 0 {
-		v.Set("recover_args", strings.Join(op.RecoverArgs, ","))
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s:%s", resp.Status, data)
 	}
 
-	return c.doPost(suffix, v, nil)
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Status returns the snapshot of the node
+func (c *Client) Status() (*Status, error) {
+	s := new(Status)
+	if err := c.doGet("/status", s); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
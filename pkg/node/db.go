@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"github.com/gorilla/mux"
 	"github.com/siddontang/chaos/pkg/core"
+	"github.com/siddontang/chaos/pkg/util"
 	"github.com/unrolled/render"
 )
 
@@ -89,7 +90,7 @@ func (h *dbHandler) TearDown(w http.ResponseWriter, r *http.Request) {
 	//node := r.FormValue("node")
 	//nodes := strings.Split(r.FormValue("nodes"), ",")
 	node := db.Node()
-	log.Printf("tear down db %s on node %s", node)
+	log.Printf("tear down db %s on node %s", db.Name(), node)
 	if err := db.TearDown(h.agent.ctx); err != nil {
 		log.Panicf("tear down db %s failed %v", node, err)
 		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
@@ -121,3 +122,44 @@ func (h *dbHandler) Kill(w http.ResponseWriter, r *http.Request) {
 
 	h.rd.JSON(w, http.StatusOK, nil)
 }
+
+func (h *dbHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	db := h.getDB(w, vars)
+	if db == nil {
+		return
+	}
+
+	service := r.FormValue("service")
+	if err := db.Ready(h.agent.ctx, service); err != nil {
+		h.rd.JSON(w, http.StatusServiceUnavailable, fmt.Sprintf("service %s is not ready on node %s: %v", service, db.Node(), err))
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+// Archive streams the tar.gz archive of the db files, data=true adds the data.
+func (h *dbHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	db := h.getDB(w, vars)
+	if db == nil {
+		return
+	}
+
+	adb, ok := db.(core.ArchiveDB)
+	if !ok {
+		h.rd.JSON(w, http.StatusNotImplemented, fmt.Sprintf("db %s can't archive its files", db.Name()))
+		return
+	}
+
+	files := adb.ArchiveFiles(r.FormValue("data") == "true")
+	log.Printf("archive %v on node %s", files, db.Node())
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.WriteHeader(http.StatusOK)
+	// The response has begun, we can only log the error.
+	if err := util.WriteTarGz(w, files); err != nil {
+		log.Printf("archive %v failed %v", files, err)
+	}
+}
@@ -0,0 +1,113 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// DefaultJournal is where the agent journals the active nemeses.
+const DefaultJournal = "/var/lib/chaos/agent.journal"
+
+// journalEntry is an active nemesis which needs to be recovered.
+type journalEntry struct {
+	ID          uint64          `json:"id"`
+	Name        string          `json:"name"`
+	RecoverArgs []string        `json:"recover_args"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Start       time.Time       `json:"start"`
+}
+
+// journal keeps the active nemeses on disk, so the agent can heal
+// the faults left by the crashed agent, like the iptables rules and the qdiscs.
+type journal struct {
+	sync.Mutex
+	path    string
+	nextID  uint64
+	entries []journalEntry
+}
+
+// openJournal loads the entries left in the journal file.
+func openJournal(name string) (*journal, error) {
+	j := &journal{path: name}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return j, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &j.entries); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range j.entries {
+		if e.ID > j.nextID {
+			j.nextID = e.ID
+		}
+	}
+	return j, nil
+}
+
+// save writes the entries to a temporary file and renames it,
+// so the journal is never half written.
+func (j *journal) save() error {
+	if len(j.path) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(j.entries)
+	if err != nil {
+		return err
+	}
+
+	os.MkdirAll(path.Dir(j.path), 0755)
+	tmp := j.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// Add journals the nemesis before invoking it and returns the entry ID.
+func (j *journal) Add(name string, recoverArgs []string, args json.RawMessage) (uint64, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	j.nextID++
+	j.entries = append(j.entries, journalEntry{
+		ID:          j.nextID,
+		Name:        name,
+		RecoverArgs: recoverArgs,
+		Args:        args,
+		Start:       time.Now(),
+	})
+	return j.nextID, j.save()
+}
+
+// Remove removes the entry after the nemesis is recovered.
+func (j *journal) Remove(id uint64) error {
+	j.Lock()
+	defer j.Unlock()
+
+	for i, e := range j.entries {
+		if e.ID == id {
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			return j.save()
+		}
+	}
+	return nil
+}
+
+// Entries returns the active entries.
+func (j *journal) Entries() []journalEntry {
+	j.Lock()
+	defer j.Unlock()
+
+	return append([]journalEntry(nil), j.entries...)
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := path.Join(dir, "agent.journal")
+	j, err := openJournal(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id1, err := j.Add("drop", []string{"n1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = j.Add("shape", nil, []byte(`{"dst": ["n2"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err = j.Remove(id1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The crashed agent leaves the shape nemesis.
+	j, err = openJournal(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := j.Entries()
+	if len(entries) != 1 || entries[0].Name != "shape" || string(entries[0].Args) != `{"dst":["n2"]}` {
+		t.Fatalf("only shape must be left, but got %v", entries)
+	}
+
+	if id, _ := j.Add("drop", nil, nil); id <= entries[0].ID {
+		t.Fatalf("new id %d must be greater than %d", id, entries[0].ID)
+	}
+}
@@ -1,11 +1,12 @@
 package node
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -36,43 +37,87 @@ func (h *nemesisHandler) getNemesis(w http.ResponseWriter, vars map[string]strin
 }
 
 func (h *nemesisHandler) Run(w http.ResponseWriter, r *http.Request) {
-	h.agent.nemesisLock.Lock()
-	defer h.agent.nemesisLock.Unlock()
-
 	vars := mux.Vars(r)
 	nemesis := h.getNemesis(w, vars)
 	if nemesis == nil {
 		return
 	}
 
+	lock := h.agent.nemesisLock(nemesis.Name())
+	lock.Lock()
+	defer lock.Unlock()
+
+	var op core.NemesisOperation
+	if err := json.NewDecoder(r.Body).Decode(&op); err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("invalid nemesis operation: %v", err))
+		return
+	}
+
 	node := r.FormValue("node")
-	invokeArgs := strings.Split(r.FormValue("invoke_args"), ",")
-	//recoverArgs := strings.Split(r.FormValue("recover_args"), ",")
-	runTime, _ := time.ParseDuration(r.FormValue("dur"))
+	invokeArgs, recoverArgs := op.InvokeArgs, op.RecoverArgs
+	runTime := op.RunTime
 	if runTime == 0 {
 		runTime = time.Second * time.Duration(rand.Intn(10)+1)
 	}
 
-	log.Printf("invoke nemesis %s with %v on node %s runtime %d", nemesis.Name(),
-		invokeArgs, node, runTime)
+	log.Printf("invoke nemesis %s with %v %s on node %s runtime %s", nemesis.Name(),
+		invokeArgs, op.Args, node, runTime)
 
-	//? why recover
-	/*defer func() {
-		log.Printf("recover nemesis %s with %v on node %s", nemesis.Name(), recoverArgs, node)
-		nemesis.Recover(h.agent.ctx, recoverArgs...)
-	}()*/
+	// Journal the nemesis before invoking, so the agent can recover it even if crashing during the run.
+	id, err := h.agent.journal.Add(nemesis.Name(), recoverArgs, op.Args)
+	if err != nil {
+		log.Printf("journal nemesis %s failed %v", nemesis.Name(), err)
+	}
 
-	if err := nemesis.Invoke(h.agent.ctx, invokeArgs...); err != nil {
+	ctx, cancel := context.WithCancel(h.agent.ctx)
+	defer cancel()
+	h.agent.startNemesis(id, cancel)
+	defer h.agent.finishNemesis(id)
+
+	if err = core.InvokeNemesis(h.agent.ctx, nemesis, invokeArgs, op.Args); err != nil {
+		// The fault is not applied, recovering it may break the healthy
+		// service, like wiping the data of a TiKV store which never left.
+		if removeErr := h.agent.journal.Remove(id); removeErr != nil {
+			log.Printf("remove nemesis %s from journal failed %v", nemesis.Name(), removeErr)
+		}
 		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Heal-all or closing the agent cancels the context to recover at once.
 	select {
-	case <-h.agent.ctx.Done():
+	case <-ctx.Done():
 	case <-time.After(runTime):
 	}
 
+	// Recover with a fresh context, the agent context may be canceled
+	// when closing, but we still need to clean up the nemesis.
+	log.Printf("recover nemesis %s with %v %s on node %s", nemesis.Name(), recoverArgs, op.Args, node)
+	if err = core.RecoverNemesis(context.Background(), nemesis, recoverArgs, op.Args); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = h.agent.journal.Remove(id); err != nil {
+		log.Printf("remove nemesis %s from journal failed %v", nemesis.Name(), err)
+	}
+
 	log.Printf("done nemesis %s running", nemesis.Name())
 
 	h.rd.JSON(w, http.StatusOK, nil)
 }
+
+// HealAll recovers all the active nemeses on the node.
+func (h *nemesisHandler) HealAll(w http.ResponseWriter, r *http.Request) {
+	log.Printf("heal all the nemeses on node %s", r.FormValue("node"))
+
+	ctx, cancel := context.WithTimeout(h.agent.ctx, 5*time.Minute)
+	defer cancel()
+
+	if err := h.agent.healAll(ctx); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -21,6 +21,7 @@ func getNodeAddr(t *testing.T) string {
 func TestNodeHandler(t *testing.T) {
 	addr := getNodeAddr(t)
 	agent := NewAgent(addr)
+	agent.SetJournal("")
 	defer agent.Close()
 
 	client := NewClient("n0", addr)
@@ -36,19 +37,15 @@ func TestNodeHandler(t *testing.T) {
 		t.Fatalf("setup db failed %v", err)
 	}
 
-	if err := client.StartDB("noop"); err != nil {
+	if err := client.Start("noop", ""); err != nil {
 		t.Fatalf("start db failed %v", err)
 	}
 
-	if !client.IsDBRunning("noop") {
-		t.Fatalf("db must be running")
+	if err := client.Ready("noop", ""); err != nil {
+		t.Fatalf("db must be ready %v", err)
 	}
 
-	if err := client.StopDB("noop"); err != nil {
-		t.Fatalf("stop db failed %v", err)
-	}
-
-	if err := client.KillDB("noop"); err != nil {
+	if err := client.Kill("noop", ""); err != nil {
 		t.Fatalf("kill db failed %v", err)
 	}
 
@@ -64,4 +61,17 @@ func TestNodeHandler(t *testing.T) {
 	}); err != nil {
 		t.Fatalf("start nemesis failed %v", err)
 	}
+
+	if err := client.HealAll(); err != nil {
+		t.Fatalf("heal all failed %v", err)
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("get status failed %v", err)
+	}
+
+	if len(status.DBs) == 0 || status.DBs[0].Name != "noop" || len(status.Nemeses) != 0 {
+		t.Fatalf("invalid status %+v", status)
+	}
 }
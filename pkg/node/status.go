@@ -0,0 +1,69 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/siddontang/chaos/pkg/core"
+	"github.com/unrolled/render"
+)
+
+// Status is the snapshot of the node.
+type Status struct {
+	Node string    `json:"node"`
+	Time time.Time `json:"time"`
+	// DBs are the registered databases, only the ones implementing
+	// core.StatusDB have the services and disk usage.
+	DBs []core.DBStatus `json:"dbs"`
+	// Nemeses are the active nemeses in the journal.
+	Nemeses []journalEntry `json:"nemeses"`
+	// Errors are the errors when taking the snapshot.
+	Errors []string `json:"errors,omitempty"`
+}
+
+type statusHandler struct {
+	agent *Agent
+	rd    *render.Render
+}
+
+func newStatusHandler(agent *Agent, rd *render.Render) *statusHandler {
+	return &statusHandler{
+		agent: agent,
+		rd:    rd,
+	}
+}
+
+// status takes the snapshot of the node, it doesn't fail for a broken DB.
+func (agent *Agent) status(ctx context.Context) *Status {
+	s := &Status{
+		Time:    time.Now(),
+		Nemeses: agent.journal.Entries(),
+	}
+	s.Node, _ = os.Hostname()
+
+	for _, name := range core.DBNames() {
+		db, ok := core.GetDB(name).(core.StatusDB)
+		if !ok {
+			s.DBs = append(s.DBs, core.DBStatus{Name: name})
+			continue
+		}
+
+		dbStatus, err := db.Status(ctx)
+		if err != nil {
+			s.Errors = append(s.Errors, err.Error())
+		}
+		dbStatus.Name = name
+		s.DBs = append(s.DBs, dbStatus)
+	}
+	return s
+}
+
+// Status returns the snapshot of the node.
+func (h *statusHandler) Status(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(h.agent.ctx, time.Minute)
+	defer cancel()
+
+	h.rd.JSON(w, http.StatusOK, h.agent.status(ctx))
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// Latest is the symlink to the latest run.
+const Latest = "latest"
+
+// TimeFormat is the format of the run timestamp.
+const TimeFormat = "20060102-150405"
+
+// Files in the run directory.
+const (
+	ConfigFile  = "config.json"
+	HistoryFile = "history.log"
+	ResultFile  = "result.json"
+	ReportFile  = "report.txt"
+)
+
+// Result is the verification result of the run.
+type Result struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// Store keeps the results of every run in <dir>/<test>/<timestamp>,
+// both <dir>/latest and <dir>/<test>/latest link to the latest run.
+type Store struct {
+	dir string
+}
+
+// New creates the store in the directory.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Run is the results of a run.
+type Run struct {
+	Test string
+	// Name is the timestamp of the run.
+	Name string
+	Dir  string
+}
+
+// relink points the latest symlink in the directory to the target.
+func relink(dir string, target string) error {
+	name := path.Join(dir, Latest)
+	tmp := name + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// NewRun creates the directory for a new run of the test started at t,
+// the runs started in the same second have the suffixes like -1 and -2.
+func (s *Store) NewRun(test string, t time.Time) (*Run, error) {
+	if err := os.MkdirAll(path.Join(s.dir, test), 0755); err != nil {
+		return nil, err
+	}
+
+	r := &Run{Test: test}
+	for i := 0; ; i++ {
+		r.Name = t.Format(TimeFormat)
+		if i > 0 {
+			r.Name = fmt.Sprintf("%s-%d", r.Name, i)
+		}
+		r.Dir = path.Join(s.dir, test, r.Name)
+
+		// Never share the directory with another run.
+		err := os.Mkdir(r.Dir, 0755)
+		if err == nil {
+			break
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+
+	if err := relink(path.Join(s.dir, test), r.Name); err != nil {
+		return nil, err
+	}
+	if err := relink(s.dir, path.Join(test, r.Name)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Tests returns the tests in the store.
+func (s *Store) Tests() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var tests []string
+	for _, info := range infos {
+		if info.IsDir() {
+			tests = append(tests, info.Name())
+		}
+	}
+	return tests, nil
+}
+
+// Runs returns the runs of the test from the oldest to the latest.
+func (s *Store) Runs(test string) ([]*Run, error) {
+	infos, err := ioutil.ReadDir(path.Join(s.dir, test))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*Run
+	for _, info := range infos {
+		// The latest symlink is not a directory here.
+		if info.IsDir() {
+			runs = append(runs, &Run{Test: test, Name: info.Name(), Dir: path.Join(s.dir, test, info.Name())})
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Name < runs[j].Name
+	})
+	return runs, nil
+}
+
+// Run returns the run of the test, the name latest means the latest run,
+// and the empty test with latest means the latest run of all the tests.
+func (s *Store) Run(test string, name string) (*Run, error) {
+	if name == Latest {
+		link := path.Join(s.dir, test, Latest)
+		target, err := os.Readlink(link)
+		if err != nil {
+			return nil, err
+		}
+		if len(test) == 0 {
+			test, name = path.Split(target)
+			test = path.Clean(test)
+		} else {
+			name = target
+		}
+	}
+
+	r := &Run{Test: test, Name: name, Dir: path.Join(s.dir, test, name)}
+	if st, err := os.Stat(r.Dir); err != nil {
+		return nil, err
+	} else if !st.IsDir() {
+		return nil, fmt.Errorf("run %s is not a directory", r.Dir)
+	}
+	return r, nil
+}
+
+// Path returns the path of the file in the run.
+func (r *Run) Path(name string) string {
+	return path.Join(r.Dir, name)
+}
+
+// SaveJSON saves v to the file in the run.
+func (r *Run) SaveJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Path(name), data, 0644)
+}
+
+// LoadJSON loads v from the file in the run.
+func (r *Run) LoadJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(r.Path(name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Result returns the verification result, nil if the run is not verified.
+func (r *Run) Result() *Result {
+	result := new(Result)
+	if err := r.LoadJSON(ResultFile, result); err != nil {
+		return nil
+	}
+	return result
+}
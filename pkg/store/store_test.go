@@ -0,0 +1,64 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(dir)
+	now := time.Now()
+	for i, test := range []string{"bank", "bank", "register"} {
+		r, err := s.NewRun(test, now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("create run failed %v", err)
+		}
+		if err = r.SaveJSON(ResultFile, Result{Valid: i != 1}); err != nil {
+			t.Fatalf("save result failed %v", err)
+		}
+	}
+
+	tests, err := s.Tests()
+	if err != nil || len(tests) != 2 {
+		t.Fatalf("invalid tests %v %v", tests, err)
+	}
+
+	runs, err := s.Runs("bank")
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("invalid runs %v %v", runs, err)
+	}
+
+	r, err := s.Run("bank", Latest)
+	if err != nil {
+		t.Fatalf("get latest run failed %v", err)
+	}
+	if r.Name != runs[1].Name || r.Result() == nil || r.Result().Valid {
+		t.Fatalf("invalid latest run %+v", r)
+	}
+
+	if r, err = s.Run("", Latest); err != nil || r.Test != "register" || !r.Result().Valid {
+		t.Fatalf("invalid latest run of all the tests %+v %v", r, err)
+	}
+
+	if _, err = s.Run("bank", "unknown"); err == nil {
+		t.Fatal("unknown run must fail")
+	}
+
+	// The runs started in the same second don't share the directory.
+	r1, err := s.NewRun("register", now)
+	if err != nil {
+		t.Fatalf("create run failed %v", err)
+	}
+	r2, err := s.NewRun("register", now)
+	if err != nil || r2.Dir == r1.Dir {
+		t.Fatalf("the runs must have different directories %+v %+v %v", r1, r2, err)
+	}
+}
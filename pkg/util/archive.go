@@ -0,0 +1,84 @@
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteTarGz writes the files and directories to w as a tar.gz archive,
+// the missing paths are skipped. The names in the archive are the
+// absolute paths without the leading slash.
+func WriteTarGz(w io.Writer, paths []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, p := range paths {
+		err := filepath.Walk(p, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+			if info.IsDir() {
+				hdr.Name += "/"
+				return tw.WriteHeader(hdr)
+			}
+
+			f, err := os.Open(name)
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			return copyPadded(tw, f, hdr.Size)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyPadded copies exactly size bytes from r to w. The file may grow while
+// archiving, so only the size in the header is copied, and it may shrink too,
+// like the truncated WAL, so the missing tail is padded with zeros.
+func copyPadded(w io.Writer, r io.Reader, size int64) error {
+	n, err := io.CopyN(w, r, size)
+	if err == io.EOF {
+		_, err = io.CopyN(w, zeroReader{}, size-n)
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
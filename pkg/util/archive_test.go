@@ -0,0 +1,60 @@
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestWriteTarGz(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(path.Join(dir, "log"), 0755)
+	name := path.Join(dir, "log", "pd.log")
+	if err = ioutil.WriteFile(name, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatalf("write log failed %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteTarGz(&buf, []string{path.Join(dir, "log"), path.Join(dir, "missing")}); err != nil {
+		t.Fatalf("write archive failed %v", err)
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("read gzip failed %v", err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("read tar failed %v", err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+
+	if data := files[strings.TrimPrefix(name, "/")]; data != "a\nb\nc\n" {
+		t.Fatalf("invalid archive %v", files)
+	}
+
+	// The file shrinks after the header is written.
+	buf.Reset()
+	if err = copyPadded(&buf, strings.NewReader("ab"), 4); err != nil || buf.String() != "ab\x00\x00" {
+		t.Fatalf("the short file must be padded, but got %q %v", buf.String(), err)
+	}
+}
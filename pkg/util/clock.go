@@ -0,0 +1,27 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// BumpClock moves the system clock forward or backward by delta.
+// It needs the CAP_SYS_TIME capability.
+func BumpClock(delta time.Duration) error {
+	tv := syscall.NsecToTimeval(time.Now().Add(delta).UnixNano())
+	return syscall.Settimeofday(&tv)
+}
+
+// ResyncClock sets the system clock from the hardware clock,
+// which BumpClock doesn't change.
+func ResyncClock(ctx context.Context) error {
+	output, err := exec.CommandContext(ctx, "hwclock", "--hctosys").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hwclock --hctosys failed %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fillFileName is the file which takes up the free space.
+const fillFileName = "chaos_fill"
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed %v: %s", name, strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Fill fills the filesystem of the directory and only leaves reserve bytes free.
+func Fill(ctx context.Context, dir string, reserve int64) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return err
+	}
+
+	size := int64(st.Bavail)*int64(st.Bsize) - reserve
+	if size <= 0 {
+		return nil
+	}
+
+	_, err := run(ctx, "fallocate", "-l", strconv.FormatInt(size, 10), path.Join(dir, fillFileName))
+	return err
+}
+
+// Unfill frees the space taken by Fill.
+func Unfill(dir string) error {
+	err := os.Remove(path.Join(dir, fillFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ReadOnly makes the directory read only by bind mounting it on itself.
+// The files opened before are still writable, so prefer Device.ReadOnly
+// if the directory is on a device.
+func ReadOnly(ctx context.Context, dir string) error {
+	if _, err := run(ctx, "mount", "--bind", dir, dir); err != nil {
+		return err
+	}
+
+	if _, err := run(ctx, "mount", "-o", "remount,ro,bind", dir); err != nil {
+		run(ctx, "umount", dir)
+		return err
+	}
+	return nil
+}
+
+// ReadWrite removes the read only bind mount of the directory created by ReadOnly,
+// it does nothing if the top mount on the directory is not read only, so the
+// device mounted on the directory is never unmounted.
+func ReadWrite(ctx context.Context, dir string) error {
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+
+	opts, ok := topMountOptions(string(data), filepath.Clean(dir))
+	if !ok || !hasOption(opts, "ro") {
+		return nil
+	}
+
+	_, err = run(ctx, "umount", dir)
+	return err
+}
+
+// topMountOptions returns the mount options of the last mount on the
+// mount point in the mountinfo, which hides the former ones.
+func topMountOptions(mountinfo string, mountPoint string) ([]string, bool) {
+	var (
+		opts  []string
+		found bool
+	)
+	for _, line := range strings.Split(mountinfo, "\n") {
+		// The fields are like "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw".
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[4] != mountPoint {
+			continue
+		}
+		opts, found = strings.Split(fields[5], ","), true
+	}
+	return opts, found
+}
+
+// MountPoint returns where the filesystem of the directory is mounted.
+func MountPoint(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+
+	mountPoint, ok := longestMountPoint(string(data), dir)
+	if !ok {
+		return "", fmt.Errorf("no mount point found for %s", dir)
+	}
+	return mountPoint, nil
+}
+
+// longestMountPoint returns the deepest mount point in the mountinfo
+// which contains the directory.
+func longestMountPoint(mountinfo string, dir string) (string, bool) {
+	var longest string
+	found := false
+	for _, line := range strings.Split(mountinfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+
+		mountPoint := fields[4]
+		if mountPoint != "/" && dir != mountPoint && !strings.HasPrefix(dir, mountPoint+"/") {
+			continue
+		}
+		if !found || len(mountPoint) > len(longest) {
+			longest, found = mountPoint, true
+		}
+	}
+	return longest, found
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Device is a device-mapper device on a loop file and mounted on the directory.
+// We inject the faults by swapping the table of the device, the filesystem
+// and the services on it don't notice until the faulty IO.
+type Device struct {
+	// Name is the device-mapper name.
+	Name string
+	// Image is the loop file under the device.
+	Image string
+	// Dir is where the device mounted.
+	Dir string
+}
+
+func (d Device) mapperPath() string {
+	return path.Join("/dev/mapper", d.Name)
+}
+
+// Exists returns whether the device is created or not.
+func (d Device) Exists() bool {
+	_, err := os.Stat(d.mapperPath())
+	return err == nil
+}
+
+// Create creates the device with the size in MB, formats and mounts it.
+func (d Device) Create(ctx context.Context, sizeMB int64) error {
+	os.MkdirAll(path.Dir(d.Image), 0755)
+	f, err := os.Create(d.Image)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(sizeMB << 20)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	loop, err := run(ctx, "losetup", "-f", "--show", d.Image)
+	if err != nil {
+		return err
+	}
+
+	table := fmt.Sprintf("0 %d linear %s 0", (sizeMB<<20)/512, loop)
+	if _, err = run(ctx, "dmsetup", "create", d.Name, "--table", table); err != nil {
+		return err
+	}
+
+	if _, err = run(ctx, "mkfs.ext4", "-q", d.mapperPath()); err != nil {
+		return err
+	}
+
+	os.MkdirAll(d.Dir, 0755)
+	_, err = run(ctx, "mount", d.mapperPath(), d.Dir)
+	return err
+}
+
+// Remove unmounts and removes the device.
+func (d Device) Remove(ctx context.Context) error {
+	run(ctx, "umount", d.Dir)
+
+	if d.Exists() {
+		if _, err := run(ctx, "dmsetup", "remove", d.Name); err != nil {
+			return err
+		}
+	}
+
+	loop, err := d.loopDevice(ctx)
+	if err != nil {
+		return nil
+	}
+	_, err = run(ctx, "losetup", "-d", loop)
+	return err
+}
+
+// loopDevice returns the loop device which the image is attached to.
+func (d Device) loopDevice(ctx context.Context) (string, error) {
+	// The output is like "/dev/loop0: [2049]:1234 (/var/lib/chaos/tikv.img)".
+	output, err := run(ctx, "losetup", "-j", d.Image)
+	if err != nil {
+		return "", err
+	}
+
+	seps := strings.SplitN(output, ":", 2)
+	if len(output) == 0 || len(seps) != 2 {
+		return "", fmt.Errorf("image %s is not attached to a loop device", d.Image)
+	}
+	return seps[0], nil
+}
+
+// sectors returns the size of the device in sectors.
+func (d Device) sectors(ctx context.Context) (string, error) {
+	output, err := run(ctx, "blockdev", "--getsz", d.mapperPath())
+	if err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// reload replaces the target of the device, the target is the table
+// without the start and the length, like "linear /dev/loop0 0".
+func (d Device) reload(ctx context.Context, target func(loop string) string) error {
+	loop, err := d.loopDevice(ctx)
+	if err != nil {
+		return err
+	}
+
+	sectors, err := d.sectors(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := fmt.Sprintf("0 %s %s", sectors, target(loop))
+	if _, err = run(ctx, "dmsetup", "suspend", d.Name); err != nil {
+		return err
+	}
+
+	_, err = run(ctx, "dmsetup", "load", d.Name, "--table", table)
+	// Always resume, or the IO on the device hangs forever.
+	if _, resumeErr := run(ctx, "dmsetup", "resume", d.Name); err == nil {
+		err = resumeErr
+	}
+	return err
+}
+
+// Delay delays all the IO on the device, including fsync.
+func (d Device) Delay(ctx context.Context, delay time.Duration) error {
+	return d.reload(ctx, func(loop string) string {
+		return fmt.Sprintf("delay %s 0 %d", loop, delay.Nanoseconds()/int64(time.Millisecond))
+	})
+}
+
+// Flakey makes the device work for up and then fails all the IO with EIO for down, repeatedly.
+func (d Device) Flakey(ctx context.Context, up time.Duration, down time.Duration) error {
+	return d.reload(ctx, func(loop string) string {
+		return fmt.Sprintf("flakey %s 0 %d %d", loop, int64(up.Seconds()), int64(down.Seconds()))
+	})
+}
+
+// ReadOnly fails all the writes on the device with EIO and keeps the reads,
+// unlike the read only mount, the opened files can't be written either.
+func (d Device) ReadOnly(ctx context.Context) error {
+	return d.reload(ctx, func(loop string) string {
+		// Zero up interval keeps the device down all the time.
+		return fmt.Sprintf("flakey %s 0 0 1 1 error_writes", loop)
+	})
+}
+
+// Restore restores the device to work normally.
+func (d Device) Restore(ctx context.Context) error {
+	return d.reload(ctx, func(loop string) string {
+		return fmt.Sprintf("linear %s 0", loop)
+	})
+}
+
+// TruncateTail cuts off the last n bytes of the file.
+func TruncateTail(name string, n int64) error {
+	st, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	size := st.Size() - n
+	if size < 0 {
+		size = 0
+	}
+	return os.Truncate(name, size)
+}
+
+// CorruptTail overwrites the last n bytes of the file with random data.
+func CorruptTail(name string, n int64) error {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := st.Size() - n
+	if offset < 0 {
+		offset = 0
+	}
+
+	buf := make([]byte, st.Size()-offset)
+	rand.Read(buf)
+	if _, err = f.WriteAt(buf, offset); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// LatestFile returns the latest modified file which matches the pattern.
+func LatestFile(pattern string) (string, error) {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		latest  string
+		modTime time.Time
+	)
+	for _, name := range names {
+		st, err := os.Stat(name)
+		if err != nil || st.IsDir() {
+			continue
+		}
+
+		if len(latest) == 0 || st.ModTime().After(modTime) {
+			latest, modTime = name, st.ModTime()
+		}
+	}
+
+	if len(latest) == 0 {
+		return "", fmt.Errorf("no file matches %s", pattern)
+	}
+	return latest, nil
+}
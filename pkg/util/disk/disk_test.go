@@ -0,0 +1,134 @@
+package disk
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFill(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(".", "var")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(tmpDir, &st); err != nil {
+		t.Fatalf("stat fs failed %v", err)
+	}
+
+	// Only take up about 1MB, we don't want to really fill the disk in test.
+	reserve := int64(st.Bavail)*int64(st.Bsize) - 1<<20
+	if err = Fill(context.Background(), tmpDir, reserve); err != nil {
+		t.Fatalf("fill disk failed %v", err)
+	}
+
+	if _, err = os.Stat(path.Join(tmpDir, fillFileName)); err != nil {
+		t.Fatalf("fill file must exist %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = Unfill(tmpDir); err != nil {
+			t.Fatalf("unfill disk failed %v", err)
+		}
+	}
+
+	if _, err = os.Stat(path.Join(tmpDir, fillFileName)); !os.IsNotExist(err) {
+		t.Fatal("fill file must be removed")
+	}
+}
+
+func TestTruncateTail(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(".", "var")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	data := []byte("hello world")
+	for _, name := range []string{"1.log", "2.log"} {
+		if err = ioutil.WriteFile(path.Join(tmpDir, name), data, 0644); err != nil {
+			t.Fatalf("write file failed %v", err)
+		}
+	}
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path.Join(tmpDir, "1.log"), old, old)
+
+	name, err := LatestFile(path.Join(tmpDir, "*.log"))
+	if err != nil || path.Base(name) != "2.log" {
+		t.Fatalf("latest file must be 2.log, but got %s %v", name, err)
+	}
+
+	if _, err = LatestFile(path.Join(tmpDir, "*.wal")); err == nil {
+		t.Fatal("no matched file must fail")
+	}
+
+	if err = TruncateTail(name, 6); err != nil {
+		t.Fatalf("truncate file failed %v", err)
+	}
+
+	if got, _ := ioutil.ReadFile(name); string(got) != "hello" {
+		t.Fatalf("file must be truncated to hello, but got %q", got)
+	}
+
+	name = path.Join(tmpDir, "1.log")
+	if err = CorruptTail(name, 5); err != nil {
+		t.Fatalf("corrupt file failed %v", err)
+	}
+
+	if got, _ := ioutil.ReadFile(name); len(got) != len(data) || string(got[:6]) != "hello " {
+		t.Fatalf("only the tail must be corrupted, but got %q", got)
+	}
+}
+
+func TestTopMountOptions(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+36 22 253:0 / /opt/tidb/tikv rw,relatime shared:2 - ext4 /dev/mapper/chaos-tikv rw
+37 36 253:0 / /opt/tidb/tikv ro,relatime shared:2 - ext4 /dev/mapper/chaos-tikv rw`
+
+	opts, ok := topMountOptions(mountinfo, "/opt/tidb/tikv")
+	if !ok || !hasOption(opts, "ro") {
+		t.Fatalf("the bind mount must be read only, but got %v", opts)
+	}
+
+	// Only the device is mounted after the bind mount is removed.
+	lines := strings.Split(mountinfo, "\n")
+	opts, ok = topMountOptions(strings.Join(lines[:2], "\n"), "/opt/tidb/tikv")
+	if !ok || hasOption(opts, "ro") {
+		t.Fatalf("the device mount must be read write, but got %v", opts)
+	}
+
+	if _, ok = topMountOptions(mountinfo, "/opt/tidb/pd"); ok {
+		t.Fatal("the directory is not a mount point")
+	}
+}
+
+func TestLongestMountPoint(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+30 22 8:2 / /opt rw,relatime shared:3 - ext4 /dev/sda2 rw
+36 30 253:0 / /opt/tidb/tikv rw,relatime shared:2 - ext4 /dev/mapper/chaos-tikv rw`
+
+	tbl := []struct {
+		dir        string
+		mountPoint string
+	}{
+		{"/opt/tidb/tikv", "/opt/tidb/tikv"},
+		{"/opt/tidb/tikv/db", "/opt/tidb/tikv"},
+		{"/opt/tidb/tikv2", "/opt"},
+		{"/var/lib/chaos", "/"},
+	}
+
+	for _, test := range tbl {
+		mountPoint, ok := longestMountPoint(mountinfo, test.dir)
+		if !ok || mountPoint != test.mountPoint {
+			t.Fatalf("%s must be mounted on %s, but got %s", test.dir, test.mountPoint, mountPoint)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// defaultDeviceName is used if we can't detect the network interface.
+const defaultDeviceName = "eth0"
+
+var (
+	deviceLock sync.RWMutex
+	device     string
+)
+
+// SetDevice sets the network interface to control for all the Net implementations
+// which don't specify one. An empty name means detecting it automatically.
+func SetDevice(name string) {
+	deviceLock.Lock()
+	device = name
+	deviceLock.Unlock()
+}
+
+func getDevice() string {
+	deviceLock.RLock()
+	defer deviceLock.RUnlock()
+	return device
+}
+
+// resolveDevice returns the interface to control, it prefers the given name,
+// then the one set by SetDevice, then the one with the default route.
+func resolveDevice(ctx context.Context, name string) string {
+	if len(name) > 0 {
+		return name
+	}
+
+	if name = getDevice(); len(name) > 0 {
+		return name
+	}
+
+	if name, err := DefaultDevice(ctx); err == nil {
+		return name
+	}
+	return defaultDeviceName
+}
+
+// DefaultDevice returns the interface which the default route goes through.
+func DefaultDevice(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "ip", "route", "show", "default").Output()
+	if err != nil {
+		return "", err
+	}
+	return parseRouteDevice(string(output))
+}
+
+// RouteDevice returns the interface which routes to the host.
+func RouteDevice(ctx context.Context, host string) (string, error) {
+	ip := HostIP(host)
+	if len(ip) == 0 {
+		return "", fmt.Errorf("can not resolve the IP of host %s", host)
+	}
+
+	output, err := exec.CommandContext(ctx, "ip", "route", "get", ip).Output()
+	if err != nil {
+		return "", err
+	}
+	return parseRouteDevice(string(output))
+}
+
+// parseRouteDevice parses the device from the output of ip route, like
+// "default via 172.18.0.1 dev eth0" or "172.18.0.3 dev ens3 src 172.18.0.2".
+func parseRouteDevice(output string) (string, error) {
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "dev" {
+			return fields[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("no device in route %q", output)
+}
+
+// Devices returns all the interfaces which are up, except the loopback.
+func Devices() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		names = append(names, iface.Name)
+	}
+	return names, nil
+}
+
+// tcCommand returns the tc command, it looks up the binary in PATH first,
+// because tc may be installed in different places.
+func tcCommand(ctx context.Context, args ...string) *exec.Cmd {
+	name, err := exec.LookPath("tc")
+	if err != nil {
+		name = "/sbin/tc"
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
@@ -11,6 +11,10 @@ import (
 // IPTables implements Net interface to simulate the network.
 type IPTables struct {
 	Net
+
+	// Device is the network interface to slow down, empty means the one
+	// set by SetDevice or the one with the default route.
+	Device string
 }
 
 // Drop drops traffic from node.
@@ -27,24 +31,44 @@ func (IPTables) Heal(ctx context.Context) error {
 }
 
 // Slow delays the network packets with opetions.
-func (IPTables) Slow(ctx context.Context, opts SlowOptions) error {
+func (t IPTables) Slow(ctx context.Context, opts SlowOptions) error {
 	mean := fmt.Sprintf("%dms", opts.Mean.Nanoseconds()/int64(time.Millisecond))
 	variance := fmt.Sprintf("%dms", opts.Variance.Nanoseconds()/int64(time.Millisecond))
-	return exec.CommandContext(ctx, "/sbin/tc", "qdisc", "add", "dev", "eth0", "root", "netem", "delay",
-		mean, variance, "distribution", opts.Distribution).Run()
+	return addDeviceNetem(ctx, resolveDevice(ctx, t.Device), []string{"delay", mean, variance, "distribution", opts.Distribution})
 }
 
 // Flaky introduces randomized packet loss.
-func (IPTables) Flaky(ctx context.Context) error {
-	return exec.CommandContext(ctx, "/sbin/tc", "qdisc", "add", "dev", "eth0", "root", "netem", "loss",
-		"20%", "75%").Run()
+func (t IPTables) Flaky(ctx context.Context) error {
+	return addDeviceNetem(ctx, resolveDevice(ctx, t.Device), []string{"loss", "20%", "75%"})
+}
+
+// Netem shapes the network packets with options.
+func (t IPTables) Netem(ctx context.Context, opts NetemOptions) error {
+	return addDeviceNetem(ctx, resolveDevice(ctx, t.Device), opts.Args())
+}
+
+// Fast removes packet loss and delays, the traffic shaped by TC is kept.
+func (t IPTables) Fast(ctx context.Context) error {
+	return removeDeviceNetem(ctx, resolveDevice(ctx, t.Device))
 }
 
-// Fast removes packet loss and delays.
-func (IPTables) Fast(ctx context.Context) error {
-	output, err := exec.CommandContext(ctx, "/sbin/tc", "qdisc", "del", "dev", "eth0", "root").CombinedOutput()
-	if err != nil && strings.Contains(string(output), "RTNETLINK answers: No such file or directory") {
-		err = nil
+// noQdiscOutputs are what tc prints when there is no root qdisc to delete,
+// the newer iproute2 complains about the handle of zero instead.
+var noQdiscOutputs = []string{
+	"RTNETLINK answers: No such file or directory",
+	"Cannot delete qdisc with handle of zero",
+}
+
+func deleteRootQdisc(ctx context.Context, dev string) error {
+	output, err := tcCommand(ctx, "qdisc", "del", "dev", dev, "root").CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	for _, s := range noQdiscOutputs {
+		if strings.Contains(string(output), s) {
+			return nil
+		}
 	}
-	return err
+	return fmt.Errorf("delete root qdisc on %s failed %v: %s", dev, err, strings.TrimSpace(string(output)))
 }
@@ -2,6 +2,8 @@ package net
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -21,6 +23,70 @@ func DefaultSlowOptions() SlowOptions {
 	}
 }
 
+// NetemOptions is used to shape the network packets with netem.
+// A zero value disables the corresponding netem option.
+type NetemOptions struct {
+	// Delay delays the packets, Jitter varies the delay with the Distribution.
+	Delay        time.Duration
+	Jitter       time.Duration
+	Distribution string
+	// Loss drops the packets randomly, in percent.
+	Loss float64
+	// Duplicate duplicates the packets randomly, in percent.
+	Duplicate float64
+	// Reorder sends the packets immediately instead of delaying them, in percent.
+	// Reorder only works with Delay.
+	Reorder float64
+	// Corrupt introduces a single bit error to the packets randomly, in percent.
+	Corrupt float64
+	// Correlation is the correlation with the previous packet for
+	// Loss, Duplicate, Reorder and Corrupt, in percent.
+	Correlation float64
+}
+
+// Args returns the netem arguments for tc.
+func (o NetemOptions) Args() []string {
+	var args []string
+	if o.Delay > 0 {
+		args = append(args, "delay", formatMillis(o.Delay))
+		if o.Jitter > 0 {
+			args = append(args, formatMillis(o.Jitter))
+			if len(o.Distribution) > 0 {
+				args = append(args, "distribution", o.Distribution)
+			}
+		}
+	}
+
+	percents := []struct {
+		name  string
+		value float64
+	}{
+		{"loss", o.Loss},
+		{"duplicate", o.Duplicate},
+		{"reorder", o.Reorder},
+		{"corrupt", o.Corrupt},
+	}
+	for _, p := range percents {
+		if p.value <= 0 {
+			continue
+		}
+		args = append(args, p.name, formatPercent(p.value))
+		if o.Correlation > 0 {
+			args = append(args, formatPercent(o.Correlation))
+		}
+	}
+
+	return args
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Nanoseconds()/int64(time.Millisecond))
+}
+
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+}
+
 // Net is used to control the network.
 type Net interface {
 	// Drop drops traffic from node.
@@ -31,6 +97,8 @@ type Net interface {
 	Slow(ctx context.Context, opts SlowOptions) error
 	// Flaky introduces randomized packet loss.
 	Flaky(ctx context.Context) error
+	// Netem shapes the network packets with options.
+	Netem(ctx context.Context, opts NetemOptions) error
 	// Fast removes packet loss and delays.
 	Fast(ctx context.Context) error
 }
@@ -52,5 +120,8 @@ func (Noop) Slow(ctx context.Context, opts SlowOptions) error { return nil }
 // Flaky introduces randomized packet loss.
 func (Noop) Flaky(ctx context.Context) error { return nil }
 
+// Netem shapes the network packets with options.
+func (Noop) Netem(ctx context.Context, opts NetemOptions) error { return nil }
+
 // Fast removes packet loss and delays.
 func (Noop) Fast(ctx context.Context) error { return nil }
@@ -2,7 +2,11 @@ package net
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsReachable(t *testing.T) {
@@ -28,3 +32,86 @@ func TestHostIP(t *testing.T) {
 		t.Fatal("must not get a host IP for n0")
 	}
 }
+
+func TestNetemArgs(t *testing.T) {
+	opts := NetemOptions{
+		Delay:        100 * time.Millisecond,
+		Jitter:       10 * time.Millisecond,
+		Distribution: "normal",
+		Loss:         20,
+		Correlation:  75,
+	}
+
+	args := strings.Join(opts.Args(), " ")
+	if args != "delay 100ms 10ms distribution normal loss 20% 75%" {
+		t.Fatalf("invalid netem args %s", args)
+	}
+
+	if len(NetemOptions{}.Args()) != 0 {
+		t.Fatal("empty options must have no netem args")
+	}
+}
+
+func TestLoadHosts(t *testing.T) {
+	f, err := ioutil.TempFile(".", "hosts")
+	if err != nil {
+		t.Fatalf("create hosts file failed %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("# chaos nodes\n10.0.0.1 n1 node1\n10.0.0.2\tn2\n\n")
+	f.Close()
+
+	if err = LoadHosts(f.Name()); err != nil {
+		t.Fatalf("load hosts failed %v", err)
+	}
+	defer SetHosts(nil)
+
+	if HostIP("n1") != "10.0.0.1" || HostIP("node1") != "10.0.0.1" || HostIP("n2") != "10.0.0.2" {
+		t.Fatal("must resolve the hosts with the topology")
+	}
+
+	if HostIP("10.0.0.3") != "10.0.0.3" {
+		t.Fatal("must resolve the IP to itself")
+	}
+}
+
+func TestParseRouteDevice(t *testing.T) {
+	for output, dev := range map[string]string{
+		"default via 172.18.0.1 dev eth0 \n":                  "eth0",
+		"172.18.0.3 dev ens3 src 172.18.0.2 uid 0\n    cache": "ens3",
+	} {
+		if name, err := parseRouteDevice(output); err != nil || name != dev {
+			t.Fatalf("must parse device %s from %q, but got %s %v", dev, output, name, err)
+		}
+	}
+
+	if _, err := parseRouteDevice("unreachable 10.0.0.1"); err == nil {
+		t.Fatal("route without device must fail")
+	}
+}
+
+func TestParseQdiscs(t *testing.T) {
+	output := `qdisc prio 1: root refcnt 2 bands 4 priomap  1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1
+qdisc netem 11: parent 1:1 limit 1000 delay 100.0ms
+qdisc netem 40: parent 1:4 limit 1000 loss 10%
+qdisc ingress ffff: parent ffff:fff1 ----------------
+qdisc noqueue 0: dev lo root refcnt 2`
+
+	qdiscs := parseQdiscs(output)
+	if len(qdiscs) != 4 {
+		t.Fatalf("must parse 4 qdiscs, but got %v", qdiscs)
+	}
+
+	if q, ok := findQdisc(qdiscs, prioHandle); !ok || q.kind != "prio" || len(q.parent) != 0 {
+		t.Fatalf("invalid root qdisc %+v", q)
+	}
+
+	if q, ok := findQdisc(qdiscs, shapedNetemHandle); !ok || q.kind != "netem" || q.parent != shapedBand {
+		t.Fatalf("invalid shaped qdisc %+v", q)
+	}
+
+	if _, ok := findQdisc(qdiscs, "12:"); ok {
+		t.Fatal("12: must not exist")
+	}
+}
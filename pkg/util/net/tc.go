@@ -0,0 +1,363 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Target is the destination of the shaped traffic.
+// An empty Host matches all the hosts and a zero Port matches all the ports.
+type Target struct {
+	Host string
+	Port int
+}
+
+// ParseTarget parses the target from host, host:port or :port.
+func ParseTarget(s string) (Target, error) {
+	var t Target
+	seps := strings.SplitN(s, ":", 2)
+	t.Host = seps[0]
+	if len(seps) == 2 {
+		port, err := strconv.Atoi(seps[1])
+		if err != nil {
+			return t, fmt.Errorf("invalid port in target %s", s)
+		}
+		t.Port = port
+	}
+
+	if len(t.Host) == 0 && t.Port == 0 {
+		return t, fmt.Errorf("empty target %s", s)
+	}
+	return t, nil
+}
+
+// String implements fmt.Stringer interface.
+func (t Target) String() string {
+	if t.Port == 0 {
+		return t.Host
+	}
+	return fmt.Sprintf("%s:%d", t.Host, t.Port)
+}
+
+// The netem and the shape nemeses share the traffic control tree of the device:
+//
+//	root 1: prio bands 4
+//	├── 1:1 - 1:3  the default bands, netem 11: - 13: slow down all the traffic
+//	└── 1:4        the shaped band, netem 40: slows down the filtered traffic
+//
+// The default priomap never uses the fourth band, so only the filtered traffic
+// gets there. Each nemesis only removes its own qdiscs, and the root is removed
+// when nothing is left on it.
+const (
+	prioHandle        = "1:"
+	shapedBand        = "1:4"
+	shapedNetemHandle = "40:"
+)
+
+var (
+	defaultBands        = []string{"1:1", "1:2", "1:3"}
+	defaultNetemHandles = []string{"11:", "12:", "13:"}
+)
+
+var (
+	deviceLocksMu sync.Mutex
+	deviceLocks   = map[string]*sync.Mutex{}
+)
+
+// lockDevice serializes the changes of the traffic control tree of the device,
+// the nemeses with different names may change it at the same time.
+func lockDevice(dev string) func() {
+	deviceLocksMu.Lock()
+	l, ok := deviceLocks[dev]
+	if !ok {
+		l = new(sync.Mutex)
+		deviceLocks[dev] = l
+	}
+	deviceLocksMu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func runTC(ctx context.Context, args ...string) error {
+	if output, err := tcCommand(ctx, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("tc %s failed %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// qdisc is a queueing discipline in the output of tc qdisc show.
+type qdisc struct {
+	kind   string
+	handle string
+	// parent is empty for the root.
+	parent string
+}
+
+// parseQdiscs parses the output like "qdisc prio 1: root refcnt 2 bands 4"
+// and "qdisc netem 40: parent 1:4 limit 1000 delay 100.0ms".
+func parseQdiscs(output string) []qdisc {
+	var qdiscs []qdisc
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "qdisc" {
+			continue
+		}
+
+		q := qdisc{kind: fields[1], handle: fields[2]}
+		if fields[3] == "parent" && len(fields) > 4 {
+			q.parent = fields[4]
+		} else if fields[3] != "root" {
+			// Like the ingress qdisc, which we don't touch.
+			continue
+		}
+		qdiscs = append(qdiscs, q)
+	}
+	return qdiscs
+}
+
+func showQdiscs(ctx context.Context, dev string) ([]qdisc, error) {
+	output, err := tcCommand(ctx, "qdisc", "show", "dev", dev).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("show qdisc on %s failed %v: %s", dev, err, strings.TrimSpace(string(output)))
+	}
+	return parseQdiscs(string(output)), nil
+}
+
+func findQdisc(qdiscs []qdisc, handle string) (qdisc, bool) {
+	for _, q := range qdiscs {
+		if q.handle == handle {
+			return q, true
+		}
+	}
+	return qdisc{}, false
+}
+
+// ensurePrio adds the root prio qdisc if the device doesn't have it yet.
+func ensurePrio(ctx context.Context, dev string, qdiscs []qdisc) error {
+	if q, ok := findQdisc(qdiscs, prioHandle); ok && q.kind == "prio" && len(q.parent) == 0 {
+		return nil
+	}
+
+	// Replace the default root qdisc of the device, like pfifo_fast or noqueue.
+	return runTC(ctx, "qdisc", "replace", "dev", dev, "root", "handle", prioHandle, "prio", "bands", "4",
+		"priomap", "1", "2", "2", "2", "1", "2", "0", "0", "1", "1", "1", "1", "1", "1", "1", "1")
+}
+
+// removePrioIfEmpty removes the root prio qdisc if no netem is left on it.
+func removePrioIfEmpty(ctx context.Context, dev string) error {
+	qdiscs, err := showQdiscs(ctx, dev)
+	if err != nil {
+		return err
+	}
+
+	root, ok := findQdisc(qdiscs, prioHandle)
+	if !ok || root.kind != "prio" {
+		return nil
+	}
+	for _, q := range qdiscs {
+		if strings.HasPrefix(q.parent, prioHandle) {
+			return nil
+		}
+	}
+	return deleteRootQdisc(ctx, dev)
+}
+
+// addDeviceNetem slows down all the traffic of the device with the netem arguments.
+func addDeviceNetem(ctx context.Context, dev string, netemArgs []string) error {
+	unlock := lockDevice(dev)
+	defer unlock()
+
+	qdiscs, err := showQdiscs(ctx, dev)
+	if err != nil {
+		return err
+	}
+	if _, ok := findQdisc(qdiscs, defaultNetemHandles[0]); ok {
+		return fmt.Errorf("netem is already set on %s", dev)
+	}
+	if err = ensurePrio(ctx, dev, qdiscs); err != nil {
+		return err
+	}
+
+	for i, band := range defaultBands {
+		args := append([]string{"qdisc", "add", "dev", dev, "parent", band, "handle", defaultNetemHandles[i], "netem"}, netemArgs...)
+		if err = runTC(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDeviceNetem removes the netem added by addDeviceNetem.
+func removeDeviceNetem(ctx context.Context, dev string) error {
+	unlock := lockDevice(dev)
+	defer unlock()
+
+	qdiscs, err := showQdiscs(ctx, dev)
+	if err != nil {
+		return err
+	}
+
+	// The netem added at the root by the old agent.
+	if root, ok := findQdisc(qdiscs, prioHandle); !ok || root.kind != "prio" {
+		for _, q := range qdiscs {
+			if len(q.parent) == 0 && q.kind == "netem" {
+				return deleteRootQdisc(ctx, dev)
+			}
+		}
+	}
+
+	for i, handle := range defaultNetemHandles {
+		if _, ok := findQdisc(qdiscs, handle); !ok {
+			continue
+		}
+		if err = runTC(ctx, "qdisc", "del", "dev", dev, "parent", defaultBands[i], "handle", handle); err != nil {
+			return err
+		}
+	}
+	return removePrioIfEmpty(ctx, dev)
+}
+
+// removeShape removes the shaping added by shapeDevice.
+func removeShape(ctx context.Context, dev string) error {
+	unlock := lockDevice(dev)
+	defer unlock()
+
+	qdiscs, err := showQdiscs(ctx, dev)
+	if err != nil {
+		return err
+	}
+	if _, ok := findQdisc(qdiscs, shapedNetemHandle); !ok {
+		return removePrioIfEmpty(ctx, dev)
+	}
+
+	// Remove the filters first, so no traffic goes to the removed band.
+	if err = runTC(ctx, "filter", "del", "dev", dev, "parent", prioHandle+"0", "prio", "1"); err != nil {
+		return err
+	}
+	if err = runTC(ctx, "qdisc", "del", "dev", dev, "parent", shapedBand, "handle", shapedNetemHandle); err != nil {
+		return err
+	}
+	return removePrioIfEmpty(ctx, dev)
+}
+
+// TC shapes the traffic to the specified targets only, other traffic,
+// like the one between the agent and the controller, is not affected.
+type TC struct {
+	// Device is the network interface to shape, empty means the one which
+	// routes to the target host, or the default one for the port only target.
+	Device string
+}
+
+// Shape shapes the outgoing packets to the targets with options.
+func (t TC) Shape(ctx context.Context, opts NetemOptions, targets ...Target) error {
+	netemArgs := opts.Args()
+	if len(netemArgs) == 0 {
+		return fmt.Errorf("no netem options to shape the traffic")
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no target to shape the traffic")
+	}
+
+	// The targets may be routed through different interfaces if the node has multiple NICs.
+	var devs []string
+	devTargets := map[string][]Target{}
+	for _, target := range targets {
+		dev, err := t.targetDevice(ctx, target)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := devTargets[dev]; !ok {
+			devs = append(devs, dev)
+		}
+		devTargets[dev] = append(devTargets[dev], target)
+	}
+
+	for _, dev := range devs {
+		if err := shapeDevice(ctx, dev, netemArgs, devTargets[dev]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t TC) targetDevice(ctx context.Context, target Target) (string, error) {
+	if len(t.Device) > 0 || len(getDevice()) > 0 || len(target.Host) == 0 {
+		return resolveDevice(ctx, t.Device), nil
+	}
+	return RouteDevice(ctx, target.Host)
+}
+
+func shapeDevice(ctx context.Context, dev string, netemArgs []string, targets []Target) error {
+	unlock := lockDevice(dev)
+	defer unlock()
+
+	qdiscs, err := showQdiscs(ctx, dev)
+	if err != nil {
+		return err
+	}
+	if _, ok := findQdisc(qdiscs, shapedNetemHandle); ok {
+		return fmt.Errorf("traffic is already shaped on %s", dev)
+	}
+	if err = ensurePrio(ctx, dev, qdiscs); err != nil {
+		return err
+	}
+
+	cmds := [][]string{
+		append([]string{"qdisc", "add", "dev", dev, "parent", shapedBand, "handle", shapedNetemHandle, "netem"}, netemArgs...),
+	}
+
+	for _, t := range targets {
+		args := []string{"filter", "add", "dev", dev, "parent", prioHandle + "0", "protocol", "ip", "prio", "1", "u32"}
+		if len(t.Host) > 0 {
+			ip := HostIP(t.Host)
+			if len(ip) == 0 {
+				return fmt.Errorf("can not resolve the IP of host %s", t.Host)
+			}
+			args = append(args, "match", "ip", "dst", ip+"/32")
+		}
+		if t.Port > 0 {
+			args = append(args, "match", "ip", "dport", strconv.Itoa(t.Port), "0xffff")
+		}
+		args = append(args, "flowid", shapedBand)
+		cmds = append(cmds, args)
+	}
+
+	for _, args := range cmds {
+		if err = runTC(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fast removes all the shaping, the netem on the whole device is kept.
+func (t TC) Fast(ctx context.Context) error {
+	if len(t.Device) > 0 || len(getDevice()) > 0 {
+		return removeShape(ctx, resolveDevice(ctx, t.Device))
+	}
+
+	devs, err := Devices()
+	if err != nil {
+		return err
+	}
+
+	// Heal all the devices even if some fail.
+	var errs []string
+	for _, dev := range devs {
+		if err = removeShape(ctx, dev); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
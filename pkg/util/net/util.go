@@ -2,8 +2,12 @@ package net
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"os/exec"
+	"strings"
+	"sync"
 )
 
 // IsReachable returns the destNode is reachable with ping.
@@ -12,11 +16,77 @@ func IsReachable(ctx context.Context, destNode string) bool {
 	return cmd.Run() == nil
 }
 
-// HostIP looks up an IP for a hostname.
+var (
+	hostsLock sync.RWMutex
+	hosts     = map[string]string{}
+)
+
+// SetHosts sets the topology which maps the hostname to the IP.
+// HostIP consults the topology before DNS.
+func SetHosts(m map[string]string) {
+	hostsLock.Lock()
+	defer hostsLock.Unlock()
+
+	hosts = make(map[string]string, len(m))
+	for host, ip := range m {
+		hosts[host] = ip
+	}
+}
+
+// LoadHosts loads the topology from a file in the /etc/hosts format,
+// every line is an IP followed by the hostnames.
+func LoadHosts(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	m := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if net.ParseIP(fields[0]) == nil {
+			return fmt.Errorf("invalid IP %s in hosts file %s", fields[0], name)
+		}
+
+		for _, host := range fields[1:] {
+			m[host] = fields[0]
+		}
+	}
+
+	SetHosts(m)
+	return nil
+}
+
+// HostIP looks up an IP for a hostname, it prefers the IPv4 one.
 func HostIP(host string) string {
+	hostsLock.RLock()
+	ip, ok := hosts[host]
+	hostsLock.RUnlock()
+	if ok {
+		return ip
+	}
+
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
 	ips, err := net.LookupIP(host)
-	if err != nil {
+	if err != nil || len(ips) == 0 {
 		return ""
 	}
+
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
 	return ips[0].String()
 }
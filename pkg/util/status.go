@@ -0,0 +1,144 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DaemonPID returns the process ID in the pid file.
+func DaemonPID(pidFile string) (int, error) {
+	s := parsePID(pidFile)
+	if len(s) == 0 {
+		return 0, fmt.Errorf("no pid in %s", pidFile)
+	}
+	return strconv.Atoi(s)
+}
+
+// clockTicks is the USER_HZ which the times in /proc are in.
+const clockTicks = 100
+
+// ProcessStartTime returns when the process started.
+func ProcessStartTime(pid int) (time.Time, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+	ticks, err := parseStartTicks(string(data))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if data, err = ioutil.ReadFile("/proc/stat"); err != nil {
+		return time.Time{}, err
+	}
+	bootTime, err := parseBootTime(string(data))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(bootTime, 0).Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// parseStartTicks returns the start time field of /proc/<pid>/stat,
+// which is the clock ticks after the system boots.
+func parseStartTicks(stat string) (uint64, error) {
+	// The command name may contain spaces, so the fields start after it.
+	i := strings.LastIndex(stat, ")")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid process stat %q", stat)
+	}
+
+	// The start time is the 22nd field and the fields after the command start from the 3rd.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("invalid process stat %q", stat)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// parseBootTime returns the boot time in /proc/stat, in seconds since the epoch.
+func parseBootTime(stat string) (int64, error) {
+	for _, line := range strings.Split(stat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no btime in /proc/stat")
+}
+
+// tailBlockSize is how much TailFile reads from the end of the file at most.
+const tailBlockSize = 64 * 1024
+
+// TailFile returns the last n lines of the file.
+func TailFile(name string, n int) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := st.Size() - tailBlockSize
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	if offset > 0 && len(lines) > 1 {
+		// The first line may be cut.
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	tail := make([]string, 0, len(lines))
+	for _, line := range lines {
+		tail = append(tail, string(line))
+	}
+	return tail, nil
+}
+
+// DirSize returns the total size of the files in the directory.
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The files may be removed while walking.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status")
+	if err != nil {
+		t.Fatalf("create temp dir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := path.Join(dir, "pd.log")
+	if err = ioutil.WriteFile(name, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatalf("write log failed %v", err)
+	}
+
+	if tail, err := TailFile(name, 2); err != nil || strings.Join(tail, ",") != "b,c" {
+		t.Fatalf("invalid tail %v %v", tail, err)
+	}
+}
+
+func TestProcessStartTime(t *testing.T) {
+	stat := "1234 (tikv server) S 1 1234 1234 0 -1 4194560 100 0 0 0 5 3 0 0 20 0 40 0 2500 1000 200"
+	if ticks, err := parseStartTicks(stat); err != nil || ticks != 2500 {
+		t.Fatalf("start ticks must be 2500, but got %d %v", ticks, err)
+	}
+
+	if bootTime, err := parseBootTime("cpu  1 2 3\nbtime 1600000000\nprocesses 10\n"); err != nil || bootTime != 1600000000 {
+		t.Fatalf("boot time must be 1600000000, but got %d %v", bootTime, err)
+	}
+
+	start, err := ProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatalf("get process start time failed %v", err)
+	}
+	if d := time.Since(start); d < 0 || d > time.Hour {
+		t.Fatalf("the test process must start just now, but started at %s", start)
+	}
+}
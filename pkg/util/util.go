@@ -165,6 +165,24 @@ func KillDaemon(ctx context.Context, cmd string, pidFile string) error {
 	return stopDaemon(ctx, cmd, pidFile, "KILL")
 }
 
+// PauseDaemon pauses the daemon process with SIGSTOP.
+func PauseDaemon(ctx context.Context, cmd string, pidFile string) error {
+	return signalDaemon(ctx, cmd, pidFile, "STOP")
+}
+
+// ResumeDaemon resumes the paused daemon process with SIGCONT.
+func ResumeDaemon(ctx context.Context, cmd string, pidFile string) error {
+	return signalDaemon(ctx, cmd, pidFile, "CONT")
+}
+
+// signalDaemon only sends the signal, unlike stopDaemon, it keeps the pid file.
+func signalDaemon(ctx context.Context, cmd string, pidFile string, sig string) error {
+	name := path.Base(cmd)
+
+	return exec.CommandContext(ctx, "start-stop-daemon", "--stop", "--pidfile", pidFile,
+		"--oknodo", "--name", name, "--signal", sig).Run()
+}
+
 func stopDaemon(ctx context.Context, cmd string, pidFile string, sig string) error {
 	name := path.Base(cmd)
 
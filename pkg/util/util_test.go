@@ -94,6 +94,18 @@ func TestDaemon(t *testing.T) {
 		t.Fatal("daemon must be running")
 	}
 
+	if err = PauseDaemon(context.Background(), cmd, pidFile); err != nil {
+		t.Fatalf("pause daemon failed %v", err)
+	}
+
+	if !IsDaemonRunning(context.Background(), cmd, pidFile) {
+		t.Fatal("paused daemon must be still running")
+	}
+
+	if err = ResumeDaemon(context.Background(), cmd, pidFile); err != nil {
+		t.Fatalf("resume daemon failed %v", err)
+	}
+
 	err = StopDaemon(context.Background(), cmd, pidFile)
 	if err != nil {
 		t.Fatalf("stop daemon failed %v", err)
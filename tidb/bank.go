@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
-	"time"
 
 	"github.com/anishathalye/porcupine"
 
@@ -25,7 +24,7 @@ type bankClient struct {
 }
 
 func (c *bankClient) Setup(ctx context.Context, node string, initData bool) error {
-	c.r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	c.r = rand.New(rand.NewSource(rand.Int63()))
 	db, err := sql.Open("mysql", fmt.Sprintf("root@tcp(%s:4000)/test", node))
 	if err != nil {
 		return err
@@ -67,11 +66,11 @@ func (c *bankClient) Close(ctx context.Context, nodes []string, node string) err
 	return c.db.Close()
 }
 
-func (c *bankClient) invokeRead(ctx context.Context, r bankRequest) bankResponse {
+func (c *bankClient) invokeRead(ctx context.Context, r bankRequest) core.Result {
+	// The failed read has no effect, so it is not indeterminate.
 	rows, err := c.db.QueryContext(ctx, "select balance from accounts")
 	if err != nil {
-		log.Printf("query error %v", err)
-		return bankResponse{Unknown: true}
+		return core.Fail(err)
 	}
 	defer rows.Close()
 
@@ -79,16 +78,15 @@ func (c *bankClient) invokeRead(ctx context.Context, r bankRequest) bankResponse
 	for rows.Next() {
 		var v int64
 		if err = rows.Scan(&v); err != nil {
-			log.Printf("scan rows error %v", err)
-			return bankResponse{Unknown: true}
+			return core.Fail(err)
 		}
 		balances = append(balances, v)
 	}
 
-	return bankResponse{Balances: balances}
+	return core.OK(bankResponse{Balances: balances})
 }
 
-func (c *bankClient) Invoke(ctx context.Context, node string, r interface{}) interface{} {
+func (c *bankClient) Invoke(ctx context.Context, node string, r interface{}) core.Result {
 	arg := r.(bankRequest)
 	if arg.Op == 0 {
 		return c.invokeRead(ctx, arg)
@@ -97,8 +95,7 @@ func (c *bankClient) Invoke(ctx context.Context, node string, r interface{}) int
 	txn, err := c.db.Begin()
 
 	if err != nil {
-		log.Printf("tx begin error %v", err)
-		return bankResponse{Ok: false}
+		return core.Fail(err)
 	}
 	defer txn.Rollback()
 
@@ -107,35 +104,31 @@ func (c *bankClient) Invoke(ctx context.Context, node string, r interface{}) int
 		toBalance   int64
 	)
 	if err = txn.QueryRowContext(ctx, "select balance from accounts where id = ? for update", arg.From).Scan(&fromBalance); err != nil {
-		log.Printf("select from error %v", err)
-		return bankResponse{Ok: false}
+		return core.Fail(err)
 	}
 
 	if err = txn.QueryRowContext(ctx, "select balance from accounts where id = ? for update", arg.To).Scan(&toBalance); err != nil {
-		log.Printf("select to error %v", err)
-		return bankResponse{Ok: false}
+		return core.Fail(err)
 	}
 
 	if fromBalance < arg.Amount {
-		return bankResponse{Ok: false}
+		return core.Fail(fmt.Errorf("account %d balance %d is less than %d", arg.From, fromBalance, arg.Amount))
 	}
 
 	if _, err = txn.ExecContext(ctx, "update accounts set balance = balance - ? where id = ?", arg.Amount, arg.From); err != nil {
-		log.Printf("update from error %v", err)
-		return bankResponse{Ok: false}
+		return core.Fail(err)
 	}
 
 	if _, err = txn.ExecContext(ctx, "update accounts set balance = balance + ? where id = ?", arg.Amount, arg.To); err != nil {
-		log.Printf("update to error %v", err)
-		return bankResponse{Ok: false}
+		return core.Fail(err)
 	}
 
 	if err = txn.Commit(); err != nil {
-		log.Printf("commit error %v", err)
-		return bankResponse{Unknown: true}
+		// The transaction may be committed even the commit fails.
+		return core.Info(err)
 	}
 
-	return bankResponse{Ok: true}
+	return core.OK(bankResponse{})
 }
 
 func (c *bankClient) NextRequest() interface{} {
@@ -157,6 +150,11 @@ func (c *bankClient) NextRequest() interface{} {
 	return r
 }
 
+// FinalRequest implements core.FinalClient, it reads all the balances.
+func (c *bankClient) FinalRequest() interface{} {
+	return bankRequest{Op: 0}
+}
+
 type bankRequest struct {
 	// 0: read
 	// 1: transfer
@@ -169,10 +167,6 @@ type bankRequest struct {
 type bankResponse struct {
 	// read result
 	Balances []int64
-	// transfer ok or not
-	Ok bool
-	// read/transfer unknown
-	Unknown bool
 }
 
 func newBankEvent(v interface{}, id uint) porcupine.Event {
@@ -195,23 +189,26 @@ func getBankModel(n int) porcupine.Model {
 		Step: func(state interface{}, input interface{}, output interface{}) (bool, interface{}) {
 			st := state.([]int64)
 			inp := input.(bankRequest)
-			out := output.(bankResponse)
+			// The output is the result, only the ok one has the response.
+			out := output.(core.Result)
 
 			if inp.Op == 0 {
 				// read
-				ok := out.Unknown || reflect.DeepEqual(st, out.Balances)
-				return ok, state
+				if out.Type != core.OutcomeOK {
+					return true, state
+				}
+				return reflect.DeepEqual(st, out.Value.(bankResponse).Balances), state
 			}
 
 			// for transfer
-			if !out.Ok && !out.Unknown {
+			if out.Type == core.OutcomeFail {
 				return true, state
 			}
 
 			newSt := append([]int64{}, st...)
 			newSt[inp.From] -= inp.Amount
 			newSt[inp.To] += inp.Amount
-			return out.Ok || out.Unknown, newSt
+			return true, newSt
 		},
 
 		Equal: func(state1, state2 interface{}) bool {
@@ -231,15 +228,14 @@ func (p bankParser) OnRequest(data json.RawMessage) (interface{}, error) {
 
 func (p bankParser) OnResponse(data json.RawMessage) (interface{}, error) {
 	r := bankResponse{}
-	err := json.Unmarshal(data, &r)
-	if r.Unknown {
+	if err := json.Unmarshal(data, &r); err != nil {
 		return nil, err
 	}
-	return r, err
+	return core.OK(r), nil
 }
 
 func (p bankParser) OnNoopResponse() interface{} {
-	return bankResponse{Unknown: true}
+	return core.Result{Type: core.OutcomeInfo}
 }
 
 // BankClientCreator creates a bank test client for tidb.
@@ -1,9 +1,11 @@
 package tidb
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/anishathalye/porcupine"
+	"github.com/siddontang/chaos/pkg/core"
 )
 
 func TestBankVerify(t *testing.T) {
@@ -11,11 +13,11 @@ func TestBankVerify(t *testing.T) {
 
 	events := []porcupine.Event{
 		newBankEvent(bankRequest{Op: 0}, 1),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 1),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 1),
 		newBankEvent(bankRequest{Op: 1, From: 0, To: 1, Amount: 500}, 2),
-		newBankEvent(bankResponse{Ok: true}, 2),
+		newBankEvent(core.OK(bankResponse{}), 2),
 		newBankEvent(bankRequest{Op: 0}, 3),
-		newBankEvent(bankResponse{Balances: []int64{500, 1500}}, 3),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{500, 1500}}), 3),
 	}
 
 	if !porcupine.CheckEvents(m, events) {
@@ -28,17 +30,17 @@ func TestBankVerifyUnknown(t *testing.T) {
 
 	events := []porcupine.Event{
 		newBankEvent(bankRequest{Op: 0}, 1),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 1),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 1),
 		newBankEvent(bankRequest{Op: 1, From: 0, To: 1, Amount: 500}, 2),
 		// write return unknow, so we consider its return time is infinite.
-		// newBankEvent(bankResponse{Unknown: true}, 2),
+		// newBankEvent(core.Result{Type: core.OutcomeInfo}, 2),
 
 		newBankEvent(bankRequest{Op: 0}, 3),
-		newBankEvent(bankResponse{Balances: []int64{500, 1500}}, 3),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{500, 1500}}), 3),
 		newBankEvent(bankRequest{Op: 0}, 4),
 
-		newBankEvent(bankResponse{Unknown: true}, 2),
-		newBankEvent(bankResponse{Unknown: true}, 4),
+		newBankEvent(core.Result{Type: core.OutcomeInfo}, 2),
+		newBankEvent(core.Result{Type: core.OutcomeInfo}, 4),
 	}
 
 	if !porcupine.CheckEvents(m, events) {
@@ -51,12 +53,12 @@ func TestBankVerifyWriteNotOk(t *testing.T) {
 
 	events := []porcupine.Event{
 		newBankEvent(bankRequest{Op: 0}, 1),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 1),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 1),
 		newBankEvent(bankRequest{Op: 1, From: 0, To: 1, Amount: 500}, 2),
-		newBankEvent(bankResponse{Ok: false}, 2),
+		newBankEvent(core.Fail(errors.New("transfer failed")), 2),
 
 		newBankEvent(bankRequest{Op: 0}, 3),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 3),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 3),
 	}
 
 	if !porcupine.CheckEvents(m, events) {
@@ -68,11 +70,11 @@ func TestBankVerifyNoLinerizable(t *testing.T) {
 
 	events := []porcupine.Event{
 		newBankEvent(bankRequest{Op: 0}, 1),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 1),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 1),
 		newBankEvent(bankRequest{Op: 1, From: 0, To: 1, Amount: 500}, 2),
-		newBankEvent(bankResponse{Ok: true}, 2),
+		newBankEvent(core.OK(bankResponse{}), 2),
 		newBankEvent(bankRequest{Op: 0}, 3),
-		newBankEvent(bankResponse{Balances: []int64{1000, 1000}}, 3),
+		newBankEvent(core.OK(bankResponse{Balances: []int64{1000, 1000}}), 3),
 	}
 
 	if porcupine.CheckEvents(m, events) {
@@ -0,0 +1,388 @@
+package tidb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// PDNode is the node which bootstraps PD.
+	PDNode = "pd"
+	// PDAddr is the address of PD which the services connect to.
+	PDAddr = "pd:2379"
+	// TiDBStatusPort is the port TiDB serves the status.
+	TiDBStatusPort = 10080
+)
+
+// Cluster queries the live state of the TiDB cluster through the PD API
+// and the TiDB status API.
+type Cluster struct {
+	pdAddr string
+	client *http.Client
+}
+
+// NewCluster creates a cluster with the PD address.
+func NewCluster(pdAddr string) *Cluster {
+	return &Cluster{
+		pdAddr: pdAddr,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type pdMember struct {
+	Name       string   `json:"name"`
+	ClientURLs []string `json:"client_urls"`
+}
+
+type pdStore struct {
+	Store struct {
+		ID        uint64 `json:"id"`
+		Address   string `json:"address"`
+		StateName string `json:"state_name"`
+	} `json:"store"`
+}
+
+// Region is the region info in PD.
+type Region struct {
+	ID       uint64 `json:"id"`
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	Leader   struct {
+		ID      uint64 `json:"id"`
+		StoreID uint64 `json:"store_id"`
+	} `json:"leader"`
+}
+
+func (c *Cluster) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s failed %s:%s", url, resp.Status, data)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (c *Cluster) do(ctx context.Context, method string, url string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed %s:%s", strings.ToLower(method), url, resp.Status, data)
+	}
+	return nil
+}
+
+func (c *Cluster) delete(ctx context.Context, url string) error {
+	return c.do(ctx, "DELETE", url, nil)
+}
+
+func (c *Cluster) pdURL(suffix string) string {
+	return fmt.Sprintf("http://%s/pd/api/v1%s", c.pdAddr, suffix)
+}
+
+// PDLeader returns the node of the PD leader.
+func (c *Cluster) PDLeader(ctx context.Context) (string, error) {
+	var leader pdMember
+	if err := c.getJSON(ctx, c.pdURL("/leader"), &leader); err != nil {
+		return "", err
+	}
+
+	// We use the node name as the PD member name.
+	return leader.Name, nil
+}
+
+// PDNodes returns the nodes of all the PD members.
+func (c *Cluster) PDNodes(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Members []pdMember `json:"members"`
+	}
+	if err := c.getJSON(ctx, c.pdURL("/members"), &resp); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(resp.Members))
+	for _, m := range resp.Members {
+		nodes = append(nodes, m.Name)
+	}
+	return nodes, nil
+}
+
+func (c *Cluster) stores(ctx context.Context) ([]pdStore, error) {
+	var resp struct {
+		Stores []pdStore `json:"stores"`
+	}
+	if err := c.getJSON(ctx, c.pdURL("/stores"), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Stores, nil
+}
+
+// TiKVNodes returns the nodes of the TiKV stores which are up.
+func (c *Cluster) TiKVNodes(ctx context.Context) ([]string, error) {
+	stores, err := c.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for _, s := range stores {
+		if s.Store.StateName != "Up" {
+			continue
+		}
+		nodes = append(nodes, addrHost(s.Store.Address))
+	}
+	return nodes, nil
+}
+
+// StoreNode returns the node of the TiKV store.
+func (c *Cluster) StoreNode(ctx context.Context, storeID uint64) (string, error) {
+	stores, err := c.stores(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range stores {
+		if s.Store.ID == storeID {
+			return addrHost(s.Store.Address), nil
+		}
+	}
+	return "", fmt.Errorf("store %d not found", storeID)
+}
+
+// StoreID returns the ID of the TiKV store which is not removed on the node.
+func (c *Cluster) StoreID(ctx context.Context, node string) (uint64, error) {
+	stores, err := c.stores(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range stores {
+		if addrHost(s.Store.Address) == node && s.Store.StateName != "Tombstone" {
+			return s.Store.ID, nil
+		}
+	}
+	return 0, notFoundError(fmt.Sprintf("no store on node %s", node))
+}
+
+// notFoundError means PD doesn't have what we look for.
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// StoreRemoved checks whether the TiKV store on the node is removed, PD
+// makes the deleted store tombstone after moving all its regions away.
+func (c *Cluster) StoreRemoved(ctx context.Context, node string) error {
+	if id, err := c.StoreID(ctx, node); err == nil {
+		return fmt.Errorf("store %d on node %s is not tombstone yet", id, node)
+	} else if _, ok := err.(notFoundError); !ok {
+		return err
+	}
+	return nil
+}
+
+// MaxReplicas returns the number of the replicas of a region in PD.
+func (c *Cluster) MaxReplicas(ctx context.Context) (int, error) {
+	var config struct {
+		MaxReplicas int `json:"max-replicas"`
+	}
+	if err := c.getJSON(ctx, c.pdURL("/config/replicate"), &config); err != nil {
+		return 0, err
+	}
+	return config.MaxReplicas, nil
+}
+
+// DeleteStore makes the TiKV store offline, PD moves its regions
+// to other stores and then makes it tombstone.
+func (c *Cluster) DeleteStore(ctx context.Context, storeID uint64) error {
+	return c.delete(ctx, c.pdURL(fmt.Sprintf("/store/%d", storeID)))
+}
+
+// DeleteMember removes the PD member on the node.
+func (c *Cluster) DeleteMember(ctx context.Context, node string) error {
+	return c.delete(ctx, c.pdURL(fmt.Sprintf("/members/name/%s", node)))
+}
+
+// Regions returns all the regions.
+func (c *Cluster) Regions(ctx context.Context) ([]Region, error) {
+	var resp struct {
+		Regions []Region `json:"regions"`
+	}
+	if err := c.getJSON(ctx, c.pdURL("/regions"), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Regions, nil
+}
+
+// RegionLeaderNode returns the node of the TiKV which holds the region leader.
+// Zero region ID means a random region.
+func (c *Cluster) RegionLeaderNode(ctx context.Context, regionID uint64) (string, error) {
+	var region Region
+	if regionID == 0 {
+		regions, err := c.Regions(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(regions) == 0 {
+			return "", fmt.Errorf("no region in cluster")
+		}
+		region = regions[rand.Intn(len(regions))]
+	} else if err := c.getJSON(ctx, c.pdURL(fmt.Sprintf("/region/id/%d", regionID)), &region); err != nil {
+		return "", err
+	}
+
+	if region.Leader.StoreID == 0 {
+		return "", fmt.Errorf("region %d has no leader", region.ID)
+	}
+	return c.StoreNode(ctx, region.Leader.StoreID)
+}
+
+// TablePeer is the peer of the table region.
+type TablePeer struct {
+	ID      uint64 `json:"id"`
+	StoreID uint64 `json:"store_id"`
+}
+
+// TableRegion is the region of the table in TiDB.
+type TableRegion struct {
+	ID     uint64      `json:"region_id"`
+	Leader TablePeer   `json:"leader"`
+	Peers  []TablePeer `json:"peers"`
+}
+
+// TableRegions returns the regions of the table records and indices
+// through the TiDB status API on the node.
+func (c *Cluster) TableRegions(ctx context.Context, node string, db string, table string) ([]TableRegion, error) {
+	var resp struct {
+		RecordRegions []TableRegion `json:"record_regions"`
+		Indices       []struct {
+			Regions []TableRegion `json:"regions"`
+		} `json:"indices"`
+	}
+
+	url := fmt.Sprintf("http://%s:%d/tables/%s/%s/regions", node, TiDBStatusPort, db, table)
+	if err := c.getJSON(ctx, url, &resp); err != nil {
+		return nil, err
+	}
+
+	regions := resp.RecordRegions
+	for _, index := range resp.Indices {
+		regions = append(regions, index.Regions...)
+	}
+	return regions, nil
+}
+
+// AddOperator asks PD to schedule the operator, like
+// {"name": "transfer-leader", "region_id": 2, "to_store_id": 1}.
+func (c *Cluster) AddOperator(ctx context.Context, op map[string]interface{}) error {
+	body, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, "POST", c.pdURL("/operators"), body)
+}
+
+// PDHealthy checks whether the PD member on the node is healthy,
+// it asks the PD on the node itself.
+func (c *Cluster) PDHealthy(ctx context.Context, node string) error {
+	var members []struct {
+		Name   string `json:"name"`
+		Health bool   `json:"health"`
+	}
+	url := fmt.Sprintf("http://%s:%d/pd/api/v1/health", node, PDClientPort)
+	if err := c.getJSON(ctx, url, &members); err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		if m.Name == node {
+			if !m.Health {
+				return fmt.Errorf("pd on node %s is unhealthy", node)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("pd on node %s is not a member", node)
+}
+
+// StoreUp checks whether the TiKV store on the node is up in PD.
+func (c *Cluster) StoreUp(ctx context.Context, node string) error {
+	stores, err := c.stores(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stores {
+		if addrHost(s.Store.Address) == node && s.Store.StateName == "Up" {
+			return nil
+		}
+	}
+	return fmt.Errorf("no store is up on node %s", node)
+}
+
+// TiDBReady checks whether TiDB on the node serves the status.
+func (c *Cluster) TiDBReady(ctx context.Context, node string) error {
+	var status struct {
+		Version string `json:"version"`
+	}
+	return c.getJSON(ctx, fmt.Sprintf("http://%s:%d/status", node, TiDBStatusPort), &status)
+}
+
+// TiDBNodes returns the nodes which are serving TiDB.
+func (c *Cluster) TiDBNodes(ctx context.Context, nodes []string) []string {
+	var tidbNodes []string
+	for _, node := range nodes {
+		var status struct {
+			Version string `json:"version"`
+		}
+		url := fmt.Sprintf("http://%s:%d/status", node, TiDBStatusPort)
+		if err := c.getJSON(ctx, url, &status); err == nil {
+			tidbNodes = append(tidbNodes, node)
+		}
+	}
+	return tidbNodes
+}
+
+func addrHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return strings.TrimSpace(addr)
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package tidb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestPD(t *testing.T) *httptest.Server {
+	responses := map[string]string{
+		"/pd/api/v1/leader":  `{"name": "pd", "client_urls": ["http://pd:2379"]}`,
+		"/pd/api/v1/members": `{"members": [{"name": "pd"}, {"name": "n1"}]}`,
+		"/pd/api/v1/stores": `{"count": 3, "stores": [
+			{"store": {"id": 1, "address": "n1:20160", "state_name": "Up"}},
+			{"store": {"id": 4, "address": "n2:20160", "state_name": "Down"}},
+			{"store": {"id": 5, "address": "n3:20160", "state_name": "Up"}}]}`,
+		"/pd/api/v1/regions": `{"count": 1, "regions": [
+			{"id": 2, "start_key": "", "end_key": "", "leader": {"id": 3, "store_id": 5}}]}`,
+		"/pd/api/v1/region/id/2":      `{"id": 2, "start_key": "", "end_key": "", "leader": {"id": 3, "store_id": 5}}`,
+		"/pd/api/v1/store/5":          `"The store is set as Offline."`,
+		"/pd/api/v1/config/replicate": `{"max-replicas": 3, "location-labels": ""}`,
+		"/pd/api/v1/members/name/n1":  `"Success!"`,
+		"/pd/api/v1/operators":        `"The operator is created."`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+}
+
+func TestCluster(t *testing.T) {
+	s := newTestPD(t)
+	defer s.Close()
+
+	c := NewCluster(strings.TrimPrefix(s.URL, "http://"))
+	ctx := context.Background()
+
+	if leader, err := c.PDLeader(ctx); err != nil || leader != "pd" {
+		t.Fatalf("PD leader must be pd, but got %s %v", leader, err)
+	}
+
+	if nodes, err := c.PDNodes(ctx); err != nil || strings.Join(nodes, ",") != "pd,n1" {
+		t.Fatalf("PD nodes must be pd,n1, but got %v %v", nodes, err)
+	}
+
+	if nodes, err := c.TiKVNodes(ctx); err != nil || strings.Join(nodes, ",") != "n1,n3" {
+		t.Fatalf("TiKV nodes must be n1,n3, but got %v %v", nodes, err)
+	}
+
+	for _, id := range []uint64{0, 2} {
+		if node, err := c.RegionLeaderNode(ctx, id); err != nil || node != "n3" {
+			t.Fatalf("region %d leader must be on n3, but got %s %v", id, node, err)
+		}
+	}
+
+	if _, err := c.RegionLeaderNode(ctx, 10); err == nil {
+		t.Fatal("unknown region must fail")
+	}
+
+	if id, err := c.StoreID(ctx, "n3"); err != nil || id != 5 {
+		t.Fatalf("store on n3 must be 5, but got %d %v", id, err)
+	}
+
+	if err := c.StoreRemoved(ctx, "n3"); err == nil {
+		t.Fatal("store on n3 must not be removed")
+	}
+
+	if err := c.StoreRemoved(ctx, "n4"); err != nil {
+		t.Fatalf("store on n4 must be removed, but got %v", err)
+	}
+
+	if replicas, err := c.MaxReplicas(ctx); err != nil || replicas != 3 {
+		t.Fatalf("max replicas must be 3, but got %d %v", replicas, err)
+	}
+
+	if err := c.DeleteStore(ctx, 5); err != nil {
+		t.Fatalf("delete store failed %v", err)
+	}
+
+	if err := c.DeleteMember(ctx, "n1"); err != nil {
+		t.Fatalf("delete member failed %v", err)
+	}
+
+	if err := c.DeleteMember(ctx, "n2"); err == nil {
+		t.Fatal("unknown member must fail")
+	}
+
+	if err := c.AddOperator(ctx, map[string]interface{}{"name": "scatter-region", "region_id": 2}); err != nil {
+		t.Fatalf("add operator failed %v", err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/siddontang/chaos/pkg/core"
 	"github.com/siddontang/chaos/pkg/util"
+	"github.com/siddontang/chaos/pkg/util/disk"
 )
 
 const (
@@ -23,6 +24,13 @@ const (
 	SERVICE_PD   = "pd"
 	SERVICE_TIKV = "tikv"
 	SERVICE_TIDB = "tidb"
+
+	// PDClientPort is the port PD serves the clients.
+	PDClientPort = 2379
+	// PDPeerPort is the port PD members talk to each other.
+	PDPeerPort = 2380
+	// TiKVPort is the port TiKV serves.
+	TiKVPort = 20160
 )
 
 var (
@@ -36,8 +44,21 @@ var (
 	pdLog   = path.Join(deployDir, "./log/pd.log")
 	tikvLog = path.Join(deployDir, "./log/tikv.log")
 	tidbLog = path.Join(deployDir, "./log/tidb.log")
+
+	// PDDataDir is the data directory of PD.
+	PDDataDir = path.Join(deployDir, "pd")
+	// TiKVDataDir is the data directory of TiKV.
+	TiKVDataDir = path.Join(deployDir, "tikv")
+	// TiKVDevice is the device which TiKV data directory is mounted on if
+	// the node supports device-mapper, so we can inject IO faults to TiKV.
+	TiKVDevice = disk.Device{Name: "chaos-tikv", Image: "/var/lib/chaos/tikv.img", Dir: TiKVDataDir}
 )
 
+const tikvDeviceSizeMB = 10240
+
+// storeRemoveTimeout is how long PD may take to move the regions away from the removed store.
+const storeRemoveTimeout = 5 * time.Minute
+
 // db is the TiDB database.
 type db struct {
 	nodes       []string
@@ -69,6 +90,11 @@ func (db *db) SetUp(ctx context.Context) error {
 
 	//db.nodes = nodes
 
+	// Unmount the old TiKV device before the deploy directory is removed.
+	if err := TiKVDevice.Remove(ctx); err != nil {
+		log.Printf("remove TiKV device failed %v", err)
+	}
+
 	if err := util.InstallArchive(ctx, archiveURL, deployDir); err != nil {
 		log.Println("Install db error.")
 		return err
@@ -77,6 +103,13 @@ func (db *db) SetUp(ctx context.Context) error {
 	os.MkdirAll(path.Join(deployDir, "conf"), 0755)
 	os.MkdirAll(path.Join(deployDir, "log"), 0755)
 
+	if _, err := exec.LookPath("dmsetup"); err == nil {
+		if err = TiKVDevice.Create(ctx, tikvDeviceSizeMB); err != nil {
+			log.Printf("create TiKV device failed %v, use the plain directory", err)
+			TiKVDevice.Remove(ctx)
+		}
+	}
+
 	if err := ioutil.WriteFile(pdConfig, []byte("[replication]\nmax-replicas=5\n[log]\nlevel = \"debug\""), 0644); err != nil {
 		log.Println("Write PD config file error.")
 		return err
@@ -118,7 +151,6 @@ func (db *db) Start(ctx context.Context, service string) error {
 	default:
 		return fmt.Errorf("start service %s not recognized", service)
 	}
-
 }
 
 func (db *db) startPD(ctx context.Context) error {
@@ -126,20 +158,21 @@ func (db *db) startPD(ctx context.Context) error {
 	pdArgs := []string{
 		fmt.Sprintf("--name=%s", node),
 		"--data-dir=pd",
-		"--client-urls=http://0.0.0.0:2379",
-		"--peer-urls=http://0.0.0.0:2380",
-		fmt.Sprintf("--advertise-client-urls=http://%s:2379", node),
-		fmt.Sprintf("--advertise-peer-urls=http://%s:2380", node),
+		fmt.Sprintf("--client-urls=http://0.0.0.0:%d", PDClientPort),
+		fmt.Sprintf("--peer-urls=http://0.0.0.0:%d", PDPeerPort),
+		fmt.Sprintf("--advertise-client-urls=http://%s:%d", node, PDClientPort),
+		fmt.Sprintf("--advertise-peer-urls=http://%s:%d", node, PDPeerPort),
 		fmt.Sprintf("--log-file=%s", pdLog),
 		fmt.Sprintf("--config=%s", pdConfig),
 	}
 
-	initialClusterArgs := fmt.Sprintf("%s=http://%s:2380", node, node)
-	pdArgs = append(pdArgs, fmt.Sprintf("--initial-cluster=%s", initialClusterArgs))
-	//
-	/*} else {
-		pdArgs = append(pdArgs, fmt.Sprint("--join=http://n1:2379"))
-	}*/
+	if node == PDNode {
+		initialClusterArgs := fmt.Sprintf("%s=http://%s:%d", node, node, PDPeerPort)
+		pdArgs = append(pdArgs, fmt.Sprintf("--initial-cluster=%s", initialClusterArgs))
+	} else {
+		// PD on other nodes is added by membership change and joins the bootstrapped cluster.
+		pdArgs = append(pdArgs, fmt.Sprintf("--join=http://%s", PDAddr))
+	}
 
 	log.Printf("start pd-server on node %s with %s", node, pdArgs)
 	pdPID := path.Join(deployDir, "pd.pid")
@@ -148,8 +181,6 @@ func (db *db) startPD(ctx context.Context) error {
 		return err
 	}
 
-	time.Sleep(5 * time.Second)
-
 	if !util.IsDaemonRunning(ctx, pdBinary, pdPID) {
 		return fmt.Errorf("fail to start pd on node %s", node)
 	}
@@ -161,13 +192,13 @@ func (db *db) startKV(ctx context.Context) error {
 	for i, n := range db.nodes {
 		pdEndpoints[i] = fmt.Sprintf("%s:2379", n)
 	}*/
-	pdEndpoints := []string{"pd:2379"}
+	pdEndpoints := []string{PDAddr}
 	node := db.currentNode
 	tikvArgs := []string{
 		fmt.Sprintf("--pd=%s", strings.Join(pdEndpoints, ",")),
 		//fmt.Sprintf("--pd=%s", pdEndpoints),
-		"--addr=0.0.0.0:20160",
-		fmt.Sprintf("--advertise-addr=%s:20160", node),
+		fmt.Sprintf("--addr=0.0.0.0:%d", TiKVPort),
+		fmt.Sprintf("--advertise-addr=%s:%d", node, TiKVPort),
 		"--data-dir=tikv",
 		fmt.Sprintf("--log-file=%s", tikvLog),
 		fmt.Sprintf("--config=%s", tikvConfig),
@@ -180,8 +211,6 @@ func (db *db) startKV(ctx context.Context) error {
 		return err
 	}
 
-	time.Sleep(5 * time.Second)
-
 	if !util.IsDaemonRunning(ctx, tikvBinary, tikvPID) {
 		return fmt.Errorf("fail to start tikv on node %s", node)
 	}
@@ -190,7 +219,7 @@ func (db *db) startKV(ctx context.Context) error {
 }
 
 func (db *db) startTiDB(ctx context.Context) error {
-	pdEndpoints := []string{"pd:2379"}
+	pdEndpoints := []string{PDAddr}
 	node := db.currentNode
 
 	tidbArgs := []string{
@@ -206,8 +235,6 @@ func (db *db) startTiDB(ctx context.Context) error {
 		return err
 	}
 
-	time.Sleep(5 * time.Second)
-
 	if !util.IsDaemonRunning(ctx, tidbBinary, tidbPID) {
 		return fmt.Errorf("fail to start tidb on node %s", node)
 	}
@@ -351,21 +378,207 @@ func (db *db) Kill(ctx context.Context, service string) error {
 	return nil
 }
 
+// serviceDaemon returns the binary and the pid file of the service.
+func serviceDaemon(service string) (string, string, error) {
+	switch service {
+	case SERVICE_TIDB:
+		return tidbBinary, path.Join(deployDir, "tidb.pid"), nil
+	case SERVICE_TIKV:
+		return tikvBinary, path.Join(deployDir, "tikv.pid"), nil
+	case SERVICE_PD:
+		return pdBinary, path.Join(deployDir, "pd.pid"), nil
+	default:
+		return "", "", fmt.Errorf("service %s not recognized", service)
+	}
+}
+
+// Pause pauses the service process with SIGSTOP
+func (db *db) Pause(ctx context.Context, service string) error {
+	log.Printf("pause service %s", service)
+
+	binary, pidFile, err := serviceDaemon(service)
+	if err != nil {
+		return err
+	}
+	return util.PauseDaemon(ctx, binary, pidFile)
+}
+
+// Resume resumes the paused service process with SIGCONT
+func (db *db) Resume(ctx context.Context, service string) error {
+	log.Printf("resume service %s", service)
+
+	binary, pidFile, err := serviceDaemon(service)
+	if err != nil {
+		return err
+	}
+	return util.ResumeDaemon(ctx, binary, pidFile)
+}
+
+// Join adds the service on the node to the cluster. TiKV joins as a new store
+// and PD joins as a new member, both with empty data.
+func (db *db) Join(ctx context.Context, service string) error {
+	log.Printf("join service %s", service)
+
+	var dataDir string
+	switch service {
+	case SERVICE_TIKV:
+		// The old store must be tombstone, or the new store with the same
+		// address conflicts with it.
+		if err := db.waitStoreRemoved(ctx); err != nil {
+			return err
+		}
+		dataDir = TiKVDataDir
+	case SERVICE_PD:
+		if db.currentNode == PDNode {
+			return fmt.Errorf("pd on node %s bootstraps the cluster and can't join", db.currentNode)
+		}
+		dataDir = PDDataDir
+	default:
+		return fmt.Errorf("join service %s not recognized", service)
+	}
+
+	// The removed store or member can't come back with the old data.
+	if err := db.Kill(ctx, service); err != nil {
+		return err
+	}
+	if err := removeContents(dataDir); err != nil {
+		return err
+	}
+	return db.Start(ctx, service)
+}
+
+// waitStoreRemoved waits for PD to move all the regions away from the
+// removed store on the node and make it tombstone.
+func (db *db) waitStoreRemoved(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, storeRemoveTimeout)
+	defer cancel()
+
+	cluster := NewCluster(PDAddr)
+	for {
+		err := cluster.StoreRemoved(ctx, db.currentNode)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for the store on node %s to be removed failed %v", db.currentNode, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Leave removes the service on the node from the cluster. PD moves the regions
+// away from the removed TiKV store, and the removed PD member is killed.
+func (db *db) Leave(ctx context.Context, service string) error {
+	log.Printf("leave service %s", service)
+
+	cluster := NewCluster(PDAddr)
+	switch service {
+	case SERVICE_TIKV:
+		id, err := cluster.StoreID(ctx, db.currentNode)
+		if err != nil {
+			return err
+		}
+		return cluster.DeleteStore(ctx, id)
+	case SERVICE_PD:
+		if db.currentNode == PDNode {
+			return fmt.Errorf("pd on node %s serves the cluster and can't leave", db.currentNode)
+		}
+		if err := cluster.DeleteMember(ctx, db.currentNode); err != nil {
+			return err
+		}
+		return db.Kill(ctx, service)
+	default:
+		return fmt.Errorf("leave service %s not recognized", service)
+	}
+}
+
+// removeContents removes everything in the directory but keeps the directory,
+// which may be a mount point.
+func removeContents(dir string) error {
+	names, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err = os.RemoveAll(path.Join(dir, name.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // IsRunning checks whether the database is running or not
 func (db *db) IsRunning(ctx context.Context, service string) bool {
-	var s string
+	var s, binary string
 	switch service {
 	case SERVICE_TIDB:
-		s = "tidb.pid"
+		s, binary = "tidb.pid", tidbBinary
 	case SERVICE_PD:
-		s = "pd.pid"
+		s, binary = "pd.pid", pdBinary
 	case SERVICE_TIKV:
-		s = "tikv.pid"
+		s, binary = "tikv.pid", tikvBinary
 	default:
 		log.Printf("[IsRunning]service %s not recognized", service)
 		return false
 	}
-	return util.IsDaemonRunning(ctx, tidbBinary, path.Join(deployDir, s))
+	return util.IsDaemonRunning(ctx, binary, path.Join(deployDir, s))
+}
+
+// Ready checks whether the service is ready to serve.
+func (db *db) Ready(ctx context.Context, service string) error {
+	c := NewCluster(PDAddr)
+	switch service {
+	case SERVICE_PD:
+		return c.PDHealthy(ctx, db.currentNode)
+	case SERVICE_TIKV:
+		return c.StoreUp(ctx, db.currentNode)
+	case SERVICE_TIDB:
+		return c.TiDBReady(ctx, db.currentNode)
+	default:
+		return fmt.Errorf("ready service %s not recognized", service)
+	}
+}
+
+// statusLogLines is how many log lines of each service the status has.
+const statusLogLines = 20
+
+// Status implements core.StatusDB.
+func (db *db) Status(ctx context.Context) (core.DBStatus, error) {
+	status := core.DBStatus{Name: db.Name(), DeployDir: deployDir}
+	logs := map[string]string{SERVICE_PD: pdLog, SERVICE_TIKV: tikvLog, SERVICE_TIDB: tidbLog}
+	for _, service := range []string{SERVICE_PD, SERVICE_TIKV, SERVICE_TIDB} {
+		binary, pidFile, _ := serviceDaemon(service)
+		s := core.ServiceStatus{
+			Name:    service,
+			Running: util.IsDaemonRunning(ctx, binary, pidFile),
+		}
+		if pid, err := util.DaemonPID(pidFile); err == nil && s.Running {
+			s.PID = pid
+			if start, err := util.ProcessStartTime(pid); err == nil {
+				s.Uptime = time.Since(start)
+			}
+		}
+		s.LogTail, _ = util.TailFile(logs[service], statusLogLines)
+		status.Services = append(status.Services, s)
+	}
+
+	var err error
+	status.DiskUsage, err = util.DirSize(deployDir)
+	return status, err
+}
+
+// ArchiveFiles implements core.ArchiveDB.
+func (db *db) ArchiveFiles(withData bool) []string {
+	files := []string{path.Join(deployDir, "log"), path.Join(deployDir, "conf")}
+	if withData {
+		files = append(files, PDDataDir, TiKVDataDir)
+	}
+	return files
 }
 
 // Name returns the unique name for the database